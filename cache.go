@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"runtime/debug"
+)
+
+const cacheFile = ".model-gen.cache"
+
+// cache remembers, for every generated file, the hash of the inputs it was
+// rendered from and of the content written, so unchanged models are
+// neither re-rendered nor rewritten.
+type cache struct {
+	Version string                `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+type cacheEntry struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// loadCache returns an empty cache when the file is missing, unreadable or
+// was written by another generator version.
+func loadCache(path string) *cache {
+	c := &cache{Version: generatorVersion(), Entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var stored cache
+	if json.Unmarshal(data, &stored) != nil || stored.Version != c.Version || stored.Entries == nil {
+		return c
+	}
+	return &stored
+}
+
+// fresh reports whether path was generated from input and has not been
+// modified or removed since.
+func (c *cache) fresh(path, input string) bool {
+	entry, ok := c.Entries[path]
+	if !ok || entry.Input != input {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && hashOf(data) == entry.Output
+}
+
+func (c *cache) put(path, input string, output []byte) {
+	c.Entries[path] = cacheEntry{Input: input, Output: hashOf(output)}
+}
+
+func (c *cache) save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func hashOf(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// generatorVersion identifies the model-gen build, so upgrading it
+// invalidates the cache.
+func generatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			version += " " + s.Value
+		}
+	}
+	return version
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// cacheRun generates the models of the schema files in the working
+// directory and returns the outputs that were written, as told by their
+// modification times.
+func cacheRun(t *testing.T, cfg *Config, force bool) []string {
+	t.Helper()
+	outputs := []string{"models/orders/orders.go", "models/users/users.go"}
+	old := time.Now().Add(-time.Hour)
+	for _, path := range outputs {
+		os.Chtimes(path, old, old)
+	}
+	diags := &Diagnostics{}
+	paths := []string{filepath.Join("models", "orders", "schema.sql"), filepath.Join("models", "users", "schema.sql")}
+	generateFiles(cfg, paths, 1, force, true, diags)
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	var written []string
+	for _, path := range outputs {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(old) {
+			written = append(written, path)
+		}
+	}
+	sort.Strings(written)
+	return written
+}
+
+func TestCache(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"go.mod":                   "module example.com/app\n",
+		"models/users/schema.sql":  "CREATE TABLE users (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+		"models/orders/schema.sql": "CREATE TABLE orders (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+	})
+	chdir(t, dir)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	template, err := builtinTemplates.ReadFile("templates/" + structTemplateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("tmpl", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("tmpl", structTemplateFile), template, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{Templates: "tmpl"}
+	all := []string{"models/orders/orders.go", "models/users/users.go"}
+	steps := []struct {
+		name   string
+		change func()
+		force  bool
+		want   []string
+	}{
+		{name: "first run", want: all},
+		{name: "nothing changed"},
+		{
+			name: "schema changed",
+			change: func() {
+				os.WriteFile(filepath.Join("models", "users", "schema.sql"), []byte("CREATE TABLE users (id STRING(36) NOT NULL, name STRING(MAX)) PRIMARY KEY (id);"), 0644)
+			},
+			want: []string{"models/users/users.go"},
+		},
+		{name: "config changed", change: func() { cfg.Validate = true }, want: all},
+		{
+			name: "template changed",
+			change: func() {
+				os.WriteFile(filepath.Join("tmpl", structTemplateFile), append(template, "{{/* v2 */}}"...), 0644)
+			},
+			want: all,
+		},
+		{
+			name:   "output edited",
+			change: func() { os.WriteFile(filepath.Join("models", "orders", "orders.go"), []byte("package orders\n"), 0644) },
+			want:   []string{"models/orders/orders.go"},
+		},
+		{
+			name:   "output removed",
+			change: func() { os.Remove(filepath.Join("models", "users", "users.go")) },
+			want:   []string{"models/users/users.go"},
+		},
+		{name: "forced", force: true, want: all},
+		{name: "nothing changed again"},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		if got := cacheRun(t, cfg, step.force); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: wrote %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), cacheFile)
+	c := loadCache(path)
+	c.put("models/users/users.go", "input", []byte("package users\n"))
+	if err := c.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadCache(path); !reflect.DeepEqual(got, c) {
+		t.Errorf("cache = %+v, want %+v", got, c)
+	}
+
+	// A cache of another generator version is dropped.
+	c.Version += " other"
+	if err := c.save(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadCache(path); len(got.Entries) != 0 || got.Version != generatorVersion() {
+		t.Errorf("cache of another version = %+v", got)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadCache(path); len(got.Entries) != 0 {
+		t.Errorf("corrupt cache = %+v", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	createChangeStreamRegex = regexp.MustCompile(`(?is)^CREATE\s+CHANGE\s+STREAM\s+(` + identPattern + `)(?:\s+FOR\s+(.*?))?(?:\s+(?:OPTIONS|WITH)\s*\((.*)\))?\s*$`)
+	watchedTableRegex       = regexp.MustCompile(`(?s)^(` + namePattern + `)\s*(?:\((.*)\))?$`)
+)
+
+// parseCreateChangeStream parses the tables and options of a change
+// stream. The tables are checked against the schema by resolveChangeStream.
+func parseCreateChangeStream(f *sourceFile, stmt span) (*ChangeStream, error) {
+	m := createChangeStreamRegex.FindStringSubmatchIndex(stmt.text)
+	stream := &ChangeStream{
+		Name: unquoteIdent(stmt.text[m[2]:m[3]]),
+		Pos:  f.pos(stmt.offset + m[2]),
+	}
+	if m[6] >= 0 {
+		stream.Options = parseOptions(stmt.text[m[6]:m[7]])
+	}
+	if m[4] < 0 {
+		return stream, nil // watches nothing until altered
+	}
+
+	watched := strings.TrimSpace(stmt.text[m[4]:m[5]])
+	if strings.EqualFold(watched, "ALL") {
+		stream.All = true
+		return stream, nil
+	}
+	for _, ref := range splitTopLevel(watched, ',') {
+		t := watchedTableRegex.FindStringSubmatch(ref)
+		if t == nil {
+			return nil, f.errorf(stmt.offset+m[4], codeSyntax, "change stream %s: cannot parse %q", stream.Name, ref)
+		}
+		table := &WatchedTable{Table: qualifiedName(t[1]), AllColumns: !strings.Contains(ref, "(")}
+		for _, c := range strings.Split(t[2], ",") {
+			if c = unquoteIdent(c); c != "" {
+				table.Columns = append(table.Columns, c)
+			}
+		}
+		stream.Tables = append(stream.Tables, table)
+	}
+	return stream, nil
+}
+
+// resolveChangeStream reports the tables and columns of stream that are
+// not in the schema.
+func resolveChangeStream(schema *Schema, stream *ChangeStream, diags *Diagnostics) {
+	for _, w := range stream.Tables {
+		table := schema.table(w.Table)
+		if table == nil {
+			diags.warnf(stream.Source, stream.Pos, codeUnknownRef, "change stream %s: unknown table %s", stream.Name, w.Table)
+			continue
+		}
+		for _, c := range w.Columns {
+			if table.column(c) == nil {
+				diags.warnf(stream.Source, stream.Pos, codeUnknownRef, "change stream %s: unknown column %s.%s", stream.Name, w.Table, c)
+			}
+		}
+	}
+}
+
+// watches returns the columns of table that stream records, nil for all of
+// them and empty for the key only, and whether it watches the table at all.
+func (s *ChangeStream) watches(table *Table) ([]string, bool) {
+	if s.All {
+		return nil, true
+	}
+	for _, w := range s.Tables {
+		if w.Table == table.qualifiedName() {
+			if w.AllColumns {
+				return nil, true
+			}
+			return append([]string{}, w.Columns...), true
+		}
+	}
+	return nil, false
+}
+
+// watchChanges records on every table the change streams that watch it,
+// for its change reader to be generated.
+func (s *Schema) watchChanges() {
+	for _, t := range s.Tables {
+		t.changeStreams = nil
+		for _, stream := range s.ChangeStreams {
+			if columns, ok := stream.watches(t); ok {
+				t.changeStreams = append(t.changeStreams, tableChangeStream{name: stream.Name, columns: columns})
+			}
+		}
+	}
+}
+
+// changesOutputPath is where the change reader of a table is written.
+func changesOutputPath(table *Table) string {
+	return fmt.Sprintf("%s_changes.go", strings.TrimSuffix(outputPath(table), ".go"))
+}
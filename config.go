@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultConfigFile = "model-gen.json"
+
+// Values of Config.Defaults.
+const (
+	defaultsOmitZero = "omit-zero"
+	defaultsWrite    = "write"
+)
+
+// Key generators of Config.Keys.
+const (
+	keyUUID4 = "uuid4"
+	keyUUID7 = "uuid7"
+)
+
+// Config is read from model-gen.json in the working directory. Every field
+// is optional; command line flags extend or override it.
+type Config struct {
+	// Dirs are walked for .sql files instead of the working directory.
+	Dirs []string `json:"dirs,omitempty"`
+	// Include and Exclude are glob patterns matched against slash separated
+	// paths relative to the working directory. "**" matches any number of
+	// directories.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Templates is a directory whose struct.tmpl replaces the built-in
+	// model template (see templates/ in this repository).
+	Templates string `json:"templates,omitempty"`
+	// Types maps SQL types to Go types, extending and overriding the
+	// built-in mapping. A key with " NOT NULL" only applies to NOT NULL
+	// columns; one without applies to both unless a more specific key
+	// exists. Inside ARRAY<...> the element type is looked up on its own.
+	Types map[string]TypeMapping `json:"types,omitempty"`
+	// Columns maps single columns, keyed "table.column" or "*.column", to
+	// Go types. It takes precedence over Types.
+	Columns map[string]TypeMapping `json:"columns,omitempty"`
+	// Protos maps proto packages to the import paths of their Go packages,
+	// for PROTO and ENUM columns.
+	Protos map[string]ProtoPackage `json:"protos,omitempty"`
+	// Strict makes unmapped column types an error instead of falling back
+	// to interface{}. It defaults to true when the CI environment variable
+	// is set.
+	Strict *bool `json:"strict,omitempty"`
+	// Nullable chooses the Go representation of nullable columns:
+	// "spanner" (spanner.NullString, ...; the default), "pointer" (*string,
+	// ...) or "generic" (a generated Null[T]).
+	Nullable string `json:"nullable,omitempty"`
+	// Tests also generates <package>_test.go, checking that every column
+	// type round-trips through the Spanner client's encoder and decoder.
+	Tests bool `json:"tests,omitempty"`
+	// Validate makes the generated Create and Update reject data that
+	// fails Validate before sending it to Spanner.
+	Validate bool `json:"validate,omitempty"`
+	// Defaults chooses how Create writes columns with a DEFAULT:
+	// "omit-zero" (the default) leaves them out while the Go value is the
+	// zero value, so that Spanner fills in the default; "write" always
+	// writes them.
+	Defaults string `json:"defaults,omitempty"`
+	// Dialect is "googlesql" or "postgresql". Unless set, it is detected
+	// per table: only GoogleSQL declares the primary key after the column
+	// list.
+	Dialect string `json:"dialect,omitempty"`
+	// Keys maps tables, by name, to the generator of their STRING key:
+	// "uuid4" or "uuid7". Create fills in an empty key with a new UUID;
+	// CreateMut does not, so call Data.SetKey before it.
+	Keys map[string]string `json:"keys,omitempty"`
+	// Migrations is a directory of numbered .sql migration files. When
+	// set, they are replayed in order to build the schema instead of
+	// discovering schema files, and every table gets a package below
+	// Models.
+	Migrations string `json:"migrations,omitempty"`
+	// Models is the directory the packages of migrated tables are written
+	// to, "models" by default.
+	Models string `json:"models,omitempty"`
+}
+
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && path == defaultConfigFile {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		var offset int64 = -1
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		}
+		if offset >= 0 {
+			return nil, newSourceFile(path, string(data)).errorf(int(offset), codeConfig, "%v", err)
+		}
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	for key, m := range c.Types {
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("types[%q]: %w", key, err)
+		}
+	}
+	for key, m := range c.Columns {
+		if !strings.Contains(key, ".") {
+			return fmt.Errorf("columns[%q]: key must be \"table.column\" or \"*.column\"", key)
+		}
+		if err := m.validate(); err != nil {
+			return fmt.Errorf("columns[%q]: %w", key, err)
+		}
+	}
+	for pkg, p := range c.Protos {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("protos[%q]: %w", pkg, err)
+		}
+	}
+	switch c.Nullable {
+	case "", nullableSpanner, nullablePointer, nullableGeneric:
+	default:
+		return fmt.Errorf("unknown nullable %q (want %s, %s or %s)", c.Nullable, nullableSpanner, nullablePointer, nullableGeneric)
+	}
+	switch c.Dialect {
+	case "", dialectGoogleSQL, dialectPostgreSQL:
+	default:
+		return fmt.Errorf("unknown dialect %q (want %s or %s)", c.Dialect, dialectGoogleSQL, dialectPostgreSQL)
+	}
+	switch c.Defaults {
+	case "", defaultsOmitZero, defaultsWrite:
+	default:
+		return fmt.Errorf("unknown defaults %q (want %s or %s)", c.Defaults, defaultsOmitZero, defaultsWrite)
+	}
+	for table, key := range c.Keys {
+		if key != keyUUID4 && key != keyUUID7 {
+			return fmt.Errorf("keys[%q]: unknown generator %q (want %s or %s)", table, key, keyUUID4, keyUUID7)
+		}
+	}
+	return nil
+}
+
+// configFlags are the flags shared by every command that discovers schema
+// files.
+type configFlags struct {
+	path       string
+	dirs       stringsFlag
+	include    stringsFlag
+	exclude    stringsFlag
+	strict     optionalBool
+	nullable   string
+	migrations string
+}
+
+func newConfigFlags(flags *flag.FlagSet) *configFlags {
+	f := &configFlags{}
+	flags.StringVar(&f.path, "config", defaultConfigFile, "path to the config file")
+	flags.Var(&f.dirs, "dir", "directory to search for .sql files (repeatable)")
+	flags.Var(&f.include, "include", "only use .sql files matching this glob (repeatable)")
+	flags.Var(&f.exclude, "exclude", "skip files and directories matching this glob (repeatable)")
+	flags.Var(&f.strict, "strict", "fail on column types without a Go mapping (default true when $CI is set)")
+	flags.StringVar(&f.nullable, "nullable", "", "representation of nullable columns: spanner, pointer or generic")
+	flags.StringVar(&f.migrations, "migrations", "", "replay the numbered .sql migrations in this directory instead of discovering schema files")
+	return f
+}
+
+// load reads the config file and applies the flags on top of it.
+func (f *configFlags) load() (*Config, error) {
+	cfg, err := loadConfig(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.dirs) > 0 {
+		cfg.Dirs = f.dirs
+	}
+	cfg.Include = append(cfg.Include, f.include...)
+	cfg.Exclude = append(cfg.Exclude, f.exclude...)
+	if f.migrations != "" {
+		cfg.Migrations = f.migrations
+	}
+	if f.strict.set {
+		cfg.Strict = &f.strict.value
+	}
+	if f.nullable != "" {
+		cfg.Nullable = f.nullable
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// optionalBool is a boolean flag that remembers whether it was given.
+type optionalBool struct {
+	set   bool
+	value bool
+}
+
+func (b *optionalBool) String() string {
+	return strconv.FormatBool(b.value)
+}
+
+func (b *optionalBool) Set(v string) error {
+	value, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	b.set, b.value = true, value
+	return nil
+}
+
+func (b *optionalBool) IsBoolFlag() bool {
+	return true
+}
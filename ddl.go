@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sourceFile is a .sql file with its comments blanked out. Blanking keeps
+// every byte offset valid, so positions can be reported against the
+// original file.
+type sourceFile struct {
+	name  string
+	text  string
+	lines []int // offsets at which lines start
+	// openComment is the offset of a block comment left open, or -1.
+	openComment int
+}
+
+func newSourceFile(name, src string) *sourceFile {
+	f := &sourceFile{name: name, lines: []int{0}}
+	f.text, f.openComment = stripComments(src)
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+func (f *sourceFile) pos(offset int) *Pos {
+	line := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return &Pos{Line: line + 1, Column: offset - f.lines[line] + 1}
+}
+
+func (f *sourceFile) errorf(offset int, code, format string, args ...interface{}) *Diagnostic {
+	pos := f.pos(offset)
+	return &Diagnostic{
+		File:     f.name,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// span is a piece of a statement together with its offset in the file.
+type span struct {
+	text   string
+	offset int
+}
+
+// statements splits the file on semicolons outside of strings, dropping
+// empty statements. Parentheses do not hide semicolons, so a statement
+// with an unbalanced one is reported and left out without swallowing the
+// statements after it.
+func (f *sourceFile) statements(diags *Diagnostics) []span {
+	if f.openComment >= 0 {
+		diags.add(f.errorf(f.openComment, codeSyntax, "unterminated comment"))
+	}
+	all := span{text: f.text}
+	var stmts []span
+	start, quoteAt := 0, 0
+	var quote byte
+	for i := 0; i < len(f.text); i++ {
+		c := f.text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++ // escaped character
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote, quoteAt = c, i
+		case c == ';':
+			stmts = f.appendStatement(stmts, all.sub(start, i), diags)
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		diags.add(f.errorf(quoteAt, codeSyntax, "unterminated string"))
+		return stmts
+	}
+	return f.appendStatement(stmts, all.sub(start, len(f.text)), diags)
+}
+
+func (f *sourceFile) appendStatement(stmts []span, stmt span, diags *Diagnostics) []span {
+	if stmt.text == "" {
+		return stmts
+	}
+	if at := unbalanced(stmt.text); at >= 0 {
+		diags.add(f.errorf(stmt.offset+at, codeSyntax, "unbalanced parenthesis"))
+		return stmts
+	}
+	return append(stmts, stmt)
+}
+
+// unbalanced returns the offset of a parenthesis of s, outside of strings,
+// that has no partner, or -1.
+func unbalanced(s string) int {
+	var open []int
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++ // escaped character
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			open = append(open, i)
+		case c == ')':
+			if len(open) == 0 {
+				return i
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return open[len(open)-1]
+	}
+	return -1
+}
+
+// sub returns the part of s between start and end, trimmed.
+func (s span) sub(start, end int) span {
+	text := s.text[start:end]
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	return span{
+		text:   strings.TrimRight(trimmed, " \t\r\n"),
+		offset: s.offset + start + len(text) - len(trimmed),
+	}
+}
+
+func splitTopLevelSpans(s span, sep byte) []span {
+	top := topLevel(s.text)
+	var parts []span
+	start := 0
+	for i := 0; i < len(s.text); i++ {
+		if top[i] && s.text[i] == sep {
+			parts = append(parts, s.sub(start, i))
+			start = i + 1
+		}
+	}
+	return append(parts, s.sub(start, len(s.text)))
+}
+
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	for _, p := range splitTopLevelSpans(span{text: s}, sep) {
+		parts = append(parts, p.text)
+	}
+	return parts
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// open, or -1. open must itself be at the top level of s.
+func closingParen(s string, open int) int {
+	top := topLevel(s)
+	for i := open + 1; i < len(s); i++ {
+		if top[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// topLevel reports for every byte of s whether it sits outside of any
+// parentheses, ARRAY<>/STRUCT<> brackets and quoted strings.
+func topLevel(s string) []bool {
+	top := make([]bool, len(s))
+	parens, angles := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++ // escaped character
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		case c == '<' && opensAngle(s[:i]):
+			angles++
+		case c == '>' && angles > 0 && (i == 0 || s[i-1] != '='):
+			angles--
+		}
+		top[i] = parens == 0 && angles == 0 && quote == 0
+	}
+	return top
+}
+
+func opensAngle(prefix string) bool {
+	prefix = strings.TrimRight(prefix, " \t\r\n")
+	if len(prefix) > len("STRUCT") {
+		prefix = prefix[len(prefix)-len("STRUCT"):]
+	}
+	prefix = strings.ToUpper(prefix)
+	return strings.HasSuffix(prefix, "ARRAY") || strings.HasSuffix(prefix, "STRUCT")
+}
+
+// stripComments replaces comments with spaces, keeping line breaks and
+// therefore offsets intact. It also returns the offset of a block comment
+// left open, which runs to the end of src, or -1.
+func stripComments(src string) (string, int) {
+	b := []byte(src)
+	open := -1
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++ // escaped character
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '#' || (c == '-' && strings.HasPrefix(src[i:], "--")):
+			for ; i < len(b) && b[i] != '\n'; i++ {
+				b[i] = ' '
+			}
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				open, end = i, len(src)
+			} else {
+				end += i + 4
+			}
+			for ; i < end; i++ {
+				if b[i] != '\n' {
+					b[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return string(b), open
+}
+
+// unquoteIdent strips the backticks or double quotes around an identifier.
+func unquoteIdent(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '`' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// nameQuoted reports whether the name of a possibly schema-qualified name
+// s is quoted, rather than its schema.
+func nameQuoted(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasSuffix(s, `"`) || strings.HasSuffix(s, "`")
+}
+
+// schemaQuoted reports whether the schema of a schema-qualified name s is
+// quoted.
+func schemaQuoted(s string) bool {
+	top := topLevel(s)
+	for i := range s {
+		if s[i] == '.' && top[i] {
+			return nameQuoted(s[:i])
+		}
+	}
+	return false
+}
+
+// splitName splits a possibly schema-qualified name such as sales.Orders
+// or `sales`.`Orders` into its unquoted schema and name.
+func splitName(s string) (schema, name string) {
+	top := topLevel(s)
+	for i := range s {
+		if s[i] == '.' && top[i] {
+			return unquoteIdent(s[:i]), unquoteIdent(s[i+1:])
+		}
+	}
+	return "", unquoteIdent(s)
+}
+
+// qualifiedName is the unquoted name of s, qualified by its schema if any.
+func qualifiedName(s string) string {
+	if schema, name := splitName(s); schema != "" {
+		return schema + "." + name
+	}
+	return unquoteIdent(s)
+}
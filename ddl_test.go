@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		want  []string
+		diags []string // line:column code message
+	}{
+		{
+			name: "plain",
+			src:  "CREATE TABLE a (x INT64) PRIMARY KEY (x);\n\n;CREATE TABLE b (y INT64) PRIMARY KEY (y)",
+			want: []string{"CREATE TABLE a (x INT64) PRIMARY KEY (x)", "CREATE TABLE b (y INT64) PRIMARY KEY (y)"},
+		},
+		{
+			name: "semicolons in strings",
+			src:  "CREATE TABLE a (x STRING(MAX) DEFAULT ('a;b'), y STRING(MAX) DEFAULT (\"c;\\\";d\")) PRIMARY KEY (x);\nCREATE TABLE `b;c` (z INT64) PRIMARY KEY (z);",
+			want: []string{
+				"CREATE TABLE a (x STRING(MAX) DEFAULT ('a;b'), y STRING(MAX) DEFAULT (\"c;\\\";d\")) PRIMARY KEY (x)",
+				"CREATE TABLE `b;c` (z INT64) PRIMARY KEY (z)",
+			},
+		},
+		{
+			name: "comments",
+			src:  "-- a; b\nCREATE TABLE a ( # x; (\n  x INT64 /* ; ) */\n) PRIMARY KEY (x);\n/* CREATE TABLE b; */",
+			want: []string{"CREATE TABLE a (       \n  x INT64          \n) PRIMARY KEY (x)"},
+		},
+		{
+			name: "comment markers in strings",
+			src:  "CREATE TABLE a (x STRING(MAX) DEFAULT ('--;/*')) PRIMARY KEY (x);\nCREATE TABLE b (y INT64) PRIMARY KEY (y);",
+			want: []string{"CREATE TABLE a (x STRING(MAX) DEFAULT ('--;/*')) PRIMARY KEY (x)", "CREATE TABLE b (y INT64) PRIMARY KEY (y)"},
+		},
+		{
+			name:  "unclosed parenthesis",
+			src:   "CREATE TABLE a (\n  x STRING(MAX,\n) PRIMARY KEY (x);\nCREATE TABLE b (y INT64) PRIMARY KEY (y);",
+			want:  []string{"CREATE TABLE b (y INT64) PRIMARY KEY (y)"},
+			diags: []string{"1:16 MG003 unbalanced parenthesis"},
+		},
+		{
+			name:  "extra parenthesis",
+			src:   "CREATE TABLE a (x INT64)) PRIMARY KEY (x);\nCREATE TABLE b (y INT64) PRIMARY KEY (y);",
+			want:  []string{"CREATE TABLE b (y INT64) PRIMARY KEY (y)"},
+			diags: []string{"1:25 MG003 unbalanced parenthesis"},
+		},
+		{
+			name:  "unterminated string",
+			src:   "CREATE TABLE a (x INT64) PRIMARY KEY (x);\nCREATE TABLE b (y STRING(MAX) DEFAULT ('b)) PRIMARY KEY (y);\nCREATE TABLE c (z INT64) PRIMARY KEY (z);",
+			want:  []string{"CREATE TABLE a (x INT64) PRIMARY KEY (x)"},
+			diags: []string{"2:40 MG003 unterminated string"},
+		},
+		{
+			name:  "unterminated comment",
+			src:   "CREATE TABLE a (x INT64) PRIMARY KEY (x);\n/* CREATE TABLE b (y INT64) PRIMARY KEY (y);",
+			want:  []string{"CREATE TABLE a (x INT64) PRIMARY KEY (x)"},
+			diags: []string{"2:1 MG003 unterminated comment"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := &Diagnostics{}
+			f := newSourceFile("schema.sql", tt.src)
+			var got []string
+			for _, stmt := range f.statements(diags) {
+				got = append(got, stmt.text)
+				if f.text[stmt.offset:stmt.offset+len(stmt.text)] != stmt.text {
+					t.Errorf("statement %q is not at offset %d", stmt.text, stmt.offset)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements:\ngot  %q\nwant %q", got, tt.want)
+			}
+			var gotDiags []string
+			for _, d := range diags.list {
+				gotDiags = append(gotDiags, fmt.Sprintf("%d:%d %s %s", d.Line, d.Column, d.Code, d.Message))
+			}
+			if !reflect.DeepEqual(gotDiags, tt.diags) {
+				t.Errorf("diagnostics:\ngot  %q\nwant %q", gotDiags, tt.diags)
+			}
+		})
+	}
+}
+
+func TestParseSchemaAfterUnbalancedStatement(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE TABLE broken (
+  id STRING(36 NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`)
+	if len(schema.Tables) != 1 || schema.Tables[0].Name != "users" {
+		t.Errorf("tables = %+v, want users only", schema.Tables)
+	}
+	if !hasDiagnostic(diags, codeSyntax, "unbalanced parenthesis") || diags.list[0].Line != 2 {
+		t.Errorf("diagnostics:\n%s", diagnosticsText(diags))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic codes are stable so editors and CI can filter on them.
+const (
+	codeIO          = "MG001" // a file could not be read or written
+	codeConfig      = "MG002" // the config file is invalid
+	codeSyntax      = "MG003" // a DDL statement could not be parsed
+	codeUnknownRef  = "MG004" // a statement references an unknown table or column
+	codeModule      = "MG005" // the Go module of an output could not be resolved
+	codeTemplate    = "MG006" // the template could not be parsed or executed
+	codeFormat      = "MG007" // the generated code is not valid Go
+	codeUnknownType = "MG008" // a column type has no Go mapping
+	codeMigration   = "MG009" // a migration conflicts with the schema built so far
+	codeConflict    = "MG010" // two models or generated names would collide
+)
+
+// Diagnostic is a problem found while generating. It implements error so
+// parse functions can return it with its position attached.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (d *Diagnostic) Error() string {
+	loc := d.File
+	if d.Line > 0 {
+		loc += fmt.Sprintf(":%d:%d", d.Line, d.Column)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", loc, d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics collects the diagnostics of a run. It is safe for concurrent
+// use.
+type Diagnostics struct {
+	mu   sync.Mutex
+	list []*Diagnostic
+}
+
+func (d *Diagnostics) add(diag *Diagnostic) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.list = append(d.list, diag)
+}
+
+// report records err as an error. A *Diagnostic is kept as is; anything
+// else is attributed to file and pos with the given code. Joined errors
+// are reported one by one.
+func (d *Diagnostics) report(file string, pos *Pos, code string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			d.report(file, pos, code, e)
+		}
+		return
+	}
+
+	var diag *Diagnostic
+	if errors.As(err, &diag) {
+		d.add(diag)
+		return
+	}
+	diag = &Diagnostic{File: file, Severity: SeverityError, Code: code, Message: err.Error()}
+	if pos != nil {
+		diag.Line, diag.Column = pos.Line, pos.Column
+	}
+	d.add(diag)
+}
+
+func (d *Diagnostics) warnf(file string, pos *Pos, code, format string, args ...interface{}) {
+	diag := &Diagnostic{File: file, Severity: SeverityWarning, Code: code, Message: fmt.Sprintf(format, args...)}
+	if pos != nil {
+		diag.Line, diag.Column = pos.Line, pos.Column
+	}
+	d.add(diag)
+}
+
+func (d *Diagnostics) hasErrors() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, diag := range d.list {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// print writes the diagnostics sorted by position, either one per line for
+// humans or as JSON lines for editors and CI.
+func (d *Diagnostics) print(w io.Writer, format string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sort.SliceStable(d.list, func(i, j int) bool {
+		a, b := d.list[i], d.list[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	enc := json.NewEncoder(w)
+	for _, diag := range d.list {
+		var err error
+		if format == "json" {
+			err = enc.Encode(diag)
+		} else {
+			_, err = fmt.Fprintln(w, diag.Error())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diagnosticsFlag(flags *flag.FlagSet) *string {
+	return flags.String("diagnostics", "human", "diagnostics output format: human or json (one object per line)")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnumType is a Go type generated for a column restricted to a list of
+// values by a CHECK (column IN (...)) constraint.
+type EnumType struct {
+	Name   string
+	Field  string // the Field constant of the column
+	Base   string // string or int64
+	Values []EnumValue
+}
+
+type EnumValue struct {
+	Name    string
+	Literal string // the value as a Go literal
+}
+
+// enumType generates the enum type of a STRING or INT64 column with a
+// list of allowed values. Nullable columns get a pointer, with a codec
+// mapping nil to NULL.
+func (r *typeRegistry) enumType(column *Column, decls *typeDecls) (TypeMapping, bool) {
+	field := toCamelCase(column.Name)
+	enum := EnumType{Name: field + "Value", Field: field}
+	switch strings.ToUpper(column.Type) {
+	case "STRING":
+		enum.Base = "string"
+	case "INT64":
+		enum.Base = "int64"
+	default:
+		return TypeMapping{}, false
+	}
+
+	for _, v := range column.Values {
+		value := EnumValue{Name: field + enumConstName(v), Literal: strconv.Quote(v)}
+		if enum.Base == "int64" {
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				return TypeMapping{}, false
+			}
+			value.Literal = v
+		}
+		enum.Values = append(enum.Values, value)
+	}
+	decls.enums = append(decls.enums, enum)
+
+	if column.NotNull {
+		return TypeMapping{Type: enum.Name}, true
+	}
+	return TypeMapping{Type: "*" + enum.Name, Codec: codecEnum}, true
+}
+
+// enumConstName turns a value such as "in-progress" or "-1" into the
+// suffix of its constant name: InProgress, Minus1.
+func enumConstName(v string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range v {
+		switch {
+		case r == '-' && b.Len() == 0:
+			b.WriteString("Minus")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+			continue
+		}
+		upper = true
+	}
+	if b.Len() == 0 {
+		return "Empty"
+	}
+	return b.String()
+}
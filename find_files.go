@@ -1,35 +1,183 @@
 package main
 
 import (
-	"log"
+	"bufio"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-func findFilePaths() []string {
+// defaultExclude is always applied on top of the configured patterns.
+var defaultExclude = []string{
+	"**/vendor/**",
+	"**/node_modules/**",
+	"**/testdata/**",
+}
+
+// ignoreFiles are read in every walked directory and in its parents up to
+// the repository root.
+var ignoreFiles = []string{".gitignore", ".modelgenignore"}
+
+// schemaFiles returns the files named on the command line, or discovers
+// them when there are none: the migrations in order if configured, or
+// else every schema file. A single "-" reads newline separated paths from
+// stdin.
+func schemaFiles(cfg *Config, args []string, diags *Diagnostics) []string {
+	if len(args) == 1 && args[0] == "-" {
+		return readFileList(os.Stdin, diags)
+	}
+	if len(args) > 0 {
+		return args
+	}
+	if cfg.Migrations != "" {
+		return migrationFiles(cfg.Migrations, diags)
+	}
+	return findFilePaths(cfg, diags)
+}
+
+func readFileList(r io.Reader, diags *Diagnostics) []string {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		diags.report("<stdin>", nil, codeIO, err)
+	}
+	return paths
+}
+
+// findFilePaths walks the configured directories for .sql files. Paths that
+// cannot be read are reported to diags and skipped.
+func findFilePaths(cfg *Config, diags *Diagnostics) []string {
 	// Get the current working directory (terminal's directory)
 	dir, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		diags.report(".", nil, codeIO, err)
+		return nil
+	}
+
+	var roots []string
+	for _, d := range cfg.Dirs {
+		roots = append(roots, filepath.Join(dir, d))
+	}
+	if len(roots) == 0 {
+		roots = workspaceRoots(dir, diags)
 	}
 
+	exclude := append(append([]string{}, defaultExclude...), cfg.Exclude...)
+
 	// Slice to hold paths of .sql files
 	var sqlFiles []string
 
-	// Walk through the directory to find .sql files
-	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		// Check if the file has a .sql extension
-		if !d.IsDir() && filepath.Ext(path) == ".sql" {
+	for _, root := range roots {
+		rules := ancestorIgnoreRules(root)
+		depth := map[string]int{filepath.Dir(root): len(rules)}
+
+		// Walk through the directory to find .sql files
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				diags.report(relSlash(dir, path), nil, codeIO, err)
+				if d != nil && d.IsDir() && path != root {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel := relSlash(dir, path)
+
+			if d.IsDir() {
+				if path != root && (strings.HasPrefix(d.Name(), ".") || matchAny(exclude, rel) || rules.ignored(path, true)) {
+					return filepath.SkipDir
+				}
+				// Rules of sibling directories no longer apply.
+				rules = rules[:depth[filepath.Dir(path)]]
+				rules = append(rules, readIgnoreRules(path)...)
+				depth[path] = len(rules)
+				return nil
+			}
+			rules = rules[:depth[filepath.Dir(path)]]
+
+			// Check if the file has a .sql extension
+			if filepath.Ext(path) != ".sql" || matchAny(exclude, rel) || rules.ignored(path, false) {
+				return nil
+			}
+			if len(cfg.Include) > 0 && !matchAny(cfg.Include, rel) {
+				return nil
+			}
 			sqlFiles = append(sqlFiles, path)
+			return nil
+		})
+	}
+	return sqlFiles
+}
+
+// workspaceRoots returns dir, plus the modules of the go.work workspace
+// rooted at dir that live outside of it.
+func workspaceRoots(dir string, diags *Diagnostics) []string {
+	roots := []string{dir}
+	workRoot, workspace, err := workspaceDirs()
+	if err != nil {
+		diags.report("go.work", nil, codeIO, err)
+	}
+	if workRoot != dir {
+		return roots
+	}
+	for _, w := range workspace {
+		if rel, err := filepath.Rel(dir, w); err == nil && !strings.HasPrefix(rel, "..") {
+			continue
 		}
-		return nil
-	})
+		roots = append(roots, w)
+	}
+	return roots
+}
 
+func relSlash(base, path string) string {
+	rel, err := filepath.Rel(base, path)
 	if err != nil {
-		log.Fatal(err)
+		return filepath.ToSlash(path)
 	}
-	return sqlFiles
+	return filepath.ToSlash(rel)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash separated path against a glob where "**"
+// stands for any number of path segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range parts {
+				if matchSegments(pattern, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chdir changes the working directory to dir until the test ends.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// findSQL runs findFilePaths in a repository made of files and returns
+// the files found, relative to it.
+func findSQL(t *testing.T, cfg *Config, files map[string]string) []string {
+	t.Helper()
+	t.Setenv("GOWORK", "off")
+	files[".git/HEAD"] = "ref: refs/heads/main\n"
+	dir := writeFiles(t, files)
+	chdir(t, dir)
+	diags := &Diagnostics{}
+	var found []string
+	for _, path := range findFilePaths(cfg, diags) {
+		found = append(found, relSlash(dir, path))
+	}
+	if len(diags.list) > 0 {
+		t.Errorf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	return found
+}
+
+// discoveryTree holds schema files next to files discovery skips.
+func discoveryTree() map[string]string {
+	return map[string]string{
+		"models/users/schema.sql":        "",
+		"models/orders/schema.sql":       "",
+		"models/orders/draft.sql":        "",
+		"models/.modelgenignore":         "# work in progress\ndraft.sql\n",
+		"models/legacy/.gitignore":       "*.sql\n!keep.sql\n",
+		"models/legacy/old.sql":          "",
+		"models/legacy/keep.sql":         "",
+		"models/testdata/fixture.sql":    "",
+		"models/users/README.md":         "",
+		"vendor/example.com/x/x.sql":     "",
+		"web/node_modules/pkg/seed.sql":  "",
+		".cache/schema.sql":              "",
+		".gitignore":                     "generated/\n",
+		"generated/schema.sql":           "",
+		"db/migrations/0001_users.sql":   "",
+		"tools/generated/nested/old.sql": "",
+	}
+}
+
+func TestFindFilePaths(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		want []string
+	}{
+		{
+			name: "default",
+			cfg:  &Config{},
+			want: []string{"db/migrations/0001_users.sql", "models/legacy/keep.sql", "models/orders/schema.sql", "models/users/schema.sql"},
+		},
+		{
+			name: "exclude",
+			cfg:  &Config{Exclude: []string{"db/**", "**/legacy/*.sql"}},
+			want: []string{"models/orders/schema.sql", "models/users/schema.sql"},
+		},
+		{
+			name: "include",
+			cfg:  &Config{Include: []string{"models/*/schema.sql"}},
+			want: []string{"models/orders/schema.sql", "models/users/schema.sql"},
+		},
+		{
+			// The ignore files above the walked directory still apply.
+			name: "dirs",
+			cfg:  &Config{Dirs: []string{"models/orders"}},
+			want: []string{"models/orders/schema.sql"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findSQL(t, tt.cfg, discoveryTree()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("found %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFileList(t *testing.T) {
+	diags := &Diagnostics{}
+	got := readFileList(strings.NewReader("models/users/schema.sql\n\n  models/orders/schema.sql \r\n"), diags)
+	if want := []string{"models/users/schema.sql", "models/orders/schema.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/vendor/**", "vendor/example.com/x/x.sql", true},
+		{"**/vendor/**", "models/vendor/x.sql", true},
+		{"**/vendor/**", "models/vendors/x.sql", false},
+		{"models/*.sql", "models/users.sql", true},
+		{"models/*.sql", "models/users/schema.sql", false},
+		{"models/**/schema.sql", "models/schema.sql", true},
+		{"models/**/schema.sql", "models/a/b/schema.sql", true},
+		{"**/*.sql", "schema.sql", true},
+		{"db/**", "db", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const onDeletePattern = `(?:\s*\bON\s+DELETE\s+(CASCADE|NO\s+ACTION|RESTRICT|SET\s+NULL|SET\s+DEFAULT))?`
+
+var (
+	foreignKeyRegex = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+(` + identPattern + `)\s+)?FOREIGN\s+KEY\s*\(([^)]*)\)\s*REFERENCES\s+(` + namePattern + `)\s*(?:\(([^)]*)\))?` + onDeletePattern)
+	referencesRegex = regexp.MustCompile(`(?is)(?:\bCONSTRAINT\s+(` + identPattern + `)\s+)?\bREFERENCES\s+(` + namePattern + `)\s*(?:\(([^)]*)\))?` + onDeletePattern)
+)
+
+// parseForeignKey parses a table constraint such as CONSTRAINT fk FOREIGN
+// KEY (a) REFERENCES t (b) ON DELETE CASCADE, or returns nil.
+func parseForeignKey(f *sourceFile, def span) *ForeignKey {
+	m := foreignKeyRegex.FindStringSubmatch(def.text)
+	if m == nil {
+		return nil
+	}
+	fk := newForeignKey(m[1], m[3], m[4], m[5])
+	fk.Pos = f.pos(def.offset)
+	fk.Columns = splitIdents(m[2])
+	return fk
+}
+
+// cutReferences cuts an inline REFERENCES t (b) out of the rest of a
+// column definition and returns it, or nil.
+func cutReferences(rest *string) *ForeignKey {
+	loc := referencesRegex.FindStringSubmatchIndex(*rest)
+	if loc == nil {
+		return nil
+	}
+	group := func(i int) string {
+		if loc[2*i] < 0 {
+			return ""
+		}
+		return (*rest)[loc[2*i]:loc[2*i+1]]
+	}
+	fk := newForeignKey(group(1), group(2), group(3), group(4))
+	*rest = (*rest)[:loc[0]] + (*rest)[loc[1]:]
+	return fk
+}
+
+func newForeignKey(name, refTable, refColumns, onDelete string) *ForeignKey {
+	fk := &ForeignKey{
+		Name:     unquoteIdent(name),
+		RefTable: qualifiedName(refTable),
+		OnDelete: strings.ToUpper(strings.Join(strings.Fields(onDelete), " ")),
+	}
+	if strings.TrimSpace(refColumns) != "" {
+		fk.RefColumns = splitIdents(refColumns)
+	}
+	return fk
+}
+
+func splitIdents(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		names = append(names, unquoteIdent(name))
+	}
+	return names
+}
+
+// resolveForeignKeys fills in the referenced columns left out for the
+// primary key, and reports foreign keys on unknown tables or columns.
+func resolveForeignKeys(schema *Schema, diags *Diagnostics) {
+	for _, t := range schema.Tables {
+		for _, fk := range t.ForeignKeys {
+			for _, c := range fk.Columns {
+				if t.column(c) == nil {
+					diags.warnf(t.Source, fk.Pos, codeUnknownRef, "table %s: foreign key on unknown column %s", t.Name, c)
+				}
+			}
+			parent := schema.table(fk.RefTable)
+			if parent == nil {
+				diags.warnf(t.Source, fk.Pos, codeUnknownRef, "table %s: foreign key references unknown table %s", t.Name, fk.RefTable)
+				continue
+			}
+			if fk.RefColumns == nil {
+				for _, k := range parent.PrimaryKey {
+					fk.RefColumns = append(fk.RefColumns, k.Column)
+				}
+			}
+			for _, c := range fk.RefColumns {
+				if parent.column(c) == nil {
+					diags.warnf(t.Source, fk.Pos, codeUnknownRef, "table %s: foreign key references unknown column %s.%s", t.Name, parent.Name, c)
+				}
+			}
+			if len(fk.RefColumns) != len(fk.Columns) {
+				diags.warnf(t.Source, fk.Pos, codeSyntax, "table %s: foreign key has %d columns but references %d", t.Name, len(fk.Columns), len(fk.RefColumns))
+			}
+		}
+	}
+}
+
+// relation is a foreign key of a table that loaders are generated for.
+type relation struct {
+	fk     *ForeignKey
+	parent *Table
+	name   string
+}
+
+// relate sets the relations of tables: the foreign keys whose columns are
+// all in the models of both tables. The loaders live in the package of
+// the referencing table and import the package of the referenced one, so
+// a foreign key closing a cycle of imports, or on a table in the same
+// package, is reported and skipped.
+func relate(tables []*Table, diags *Diagnostics) {
+	byName := map[string]*Table{}
+	for _, t := range tables {
+		if !t.view {
+			byName[t.qualifiedName()] = t
+		}
+		t.relations, t.referenced = nil, false
+	}
+	imports := map[*Table]map[*Table]bool{}
+	var imported func(from, to *Table) bool
+	imported = func(from, to *Table) bool {
+		if from == to {
+			return true
+		}
+		for next := range imports[from] {
+			if imported(next, to) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, t := range tables {
+		if t.view {
+			continue
+		}
+		names := map[string]bool{}
+		for _, fk := range t.ForeignKeys {
+			parent := byName[fk.RefTable]
+			if parent == nil || !modelColumns(t, fk.Columns) || !modelColumns(parent, fk.RefColumns) || len(fk.Columns) != len(fk.RefColumns) {
+				continue
+			}
+			if parent != t && filepath.Clean(parent.dir()) == filepath.Clean(t.dir()) {
+				diags.warnf(t.Source, fk.Pos, codeUnknownRef, "table %s: foreign key on %s references %s in the same package, no loaders generated",
+					t.Name, strings.Join(fk.Columns, ", "), parent.Name)
+				continue
+			}
+			if parent != t && imported(parent, t) {
+				diags.warnf(t.Source, fk.Pos, codeUnknownRef, "table %s: foreign key on %s would make an import cycle with %s, no loaders generated",
+					t.Name, strings.Join(fk.Columns, ", "), parent.Name)
+				continue
+			}
+			if parent != t {
+				if imports[t] == nil {
+					imports[t] = map[*Table]bool{}
+				}
+				imports[t][parent] = true
+			}
+			name := relationName(fk, parent)
+			if names[name] {
+				name = toCamelCase(toSnakeCase(fk.Name))
+				if fk.Name == "" {
+					name = toCamelCase(toSnakeCase(strings.Join(fk.Columns, "_")))
+				}
+			}
+			names[name] = true
+			t.relations = append(t.relations, relation{fk: fk, parent: parent, name: name})
+			parent.referenced = true
+		}
+	}
+}
+
+// relationName names a relation after its column without an _id suffix,
+// such as Assistant for assistant_id, or after the referenced table if
+// the key has several columns.
+func relationName(fk *ForeignKey, parent *Table) string {
+	if len(fk.Columns) != 1 {
+		return toCamelCase(toSnakeCase(parent.Name))
+	}
+	name := toSnakeCase(fk.Columns[0])
+	if trimmed := strings.TrimSuffix(strings.ToLower(name), "_id"); trimmed != "" && trimmed != strings.ToLower(name) {
+		name = name[:len(trimmed)]
+	}
+	return toCamelCase(name)
+}
+
+func modelColumns(table *Table, names []string) bool {
+	for _, name := range names {
+		if c := table.column(name); c == nil || !modelColumn(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// RelationData is a foreign key of the table, for the loaders of the
+// parent row it references and the lister of the children of a parent.
+type RelationData struct {
+	// Name is the relation, used in the names of its methods.
+	Name        string
+	ParentTable string
+	// Parent qualifies the identifiers of the parent's package, such as
+	// "users.", and is empty if the table references itself.
+	Parent  string
+	Columns []RelationColumn
+	// ByKey is set if the foreign key references the primary key of the
+	// parent, which the Load methods read by key.
+	ByKey bool
+}
+
+// RelationColumn is a column of a foreign key and the field of the parent
+// it references.
+type RelationColumn struct {
+	Field       string
+	ParentField string
+	ParentName  string
+}
+
+// relationsData returns the relations of table and adds the imports of
+// the parent packages to imports.
+func relationsData(table *Table, imports map[string]bool) ([]RelationData, error) {
+	var relations []RelationData
+	for _, r := range table.relations {
+		data := RelationData{Name: r.name, ParentTable: r.parent.Name}
+		if r.parent != table {
+			dir := r.parent.dir()
+			module, err := moduleFor(dir)
+			if err != nil {
+				return nil, err
+			}
+			parentPath, err := module.importPath(dir)
+			if err != nil {
+				return nil, err
+			}
+			name := packageName(r.parent)
+			spec := fmt.Sprintf("%q", parentPath)
+			if name != path.Base(parentPath) {
+				spec = name + " " + spec
+			}
+			imports[spec] = true
+			data.Parent = name + "."
+		}
+		for i, c := range r.fk.Columns {
+			data.Columns = append(data.Columns, RelationColumn{
+				Field:       toCamelCase(c),
+				ParentField: toCamelCase(r.fk.RefColumns[i]),
+				ParentName:  columnName(r.parent, r.fk.RefColumns[i]),
+			})
+		}
+		data.ByKey = len(r.fk.RefColumns) == len(r.parent.PrimaryKey)
+		for i := 0; data.ByKey && i < len(r.fk.RefColumns); i++ {
+			data.ByKey = r.fk.RefColumns[i] == r.parent.PrimaryKey[i].Column
+		}
+		relations = append(relations, data)
+	}
+	return relations, nil
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/template"
+)
+
+type generator struct {
+	tmpl *template.Template
+	// testTmpl renders the round-trip tests; nil unless enabled.
+	testTmpl *template.Template
+	// changesTmpl renders the change readers of watched tables.
+	changesTmpl *template.Template
+	types       *typeRegistry
+	cache       *cache
+	force       bool
+	quiet       bool
+	validate    bool
+	// omitDefaults leaves zero values of DEFAULT columns out of inserts.
+	omitDefaults bool
+	// keys are the key generators of tables by name.
+	keys    map[string]string
+	workers int
+	// salt hashes everything besides the template data that shapes the
+	// output: generator version, config and templates.
+	salt string
+}
+
+func newGenerator(cfg *Config, workers int, force bool) (*generator, error) {
+	text, tmpl, err := parseTemplate(cfg, structTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	changesText, changesTmpl, err := parseTemplate(cfg, changesTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	salt := [][]byte{[]byte(generatorVersion()), cfgJSON, []byte(text), []byte(changesText)}
+
+	var testTmpl *template.Template
+	if cfg.Tests {
+		var testText string
+		testText, testTmpl, err = parseTemplate(cfg, testTemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		salt = append(salt, []byte(testText))
+	}
+
+	return &generator{
+		tmpl:         tmpl,
+		testTmpl:     testTmpl,
+		changesTmpl:  changesTmpl,
+		types:        newTypeRegistry(cfg),
+		cache:        loadCache(cacheFile),
+		force:        force,
+		validate:     cfg.Validate,
+		omitDefaults: cfg.Defaults != defaultsWrite,
+		keys:         cfg.Keys,
+		workers:      workers,
+		salt:         hashOf(salt...),
+	}, nil
+}
+
+func parseTemplate(cfg *Config, name string) (string, *template.Template, error) {
+	text, path, err := loadTemplate(cfg, name)
+	if err != nil {
+		return "", nil, &Diagnostic{
+			File:     path,
+			Severity: SeverityError,
+			Code:     codeIO,
+			Message:  err.Error(),
+		}
+	}
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", nil, &Diagnostic{
+			File:     path,
+			Severity: SeverityError,
+			Code:     codeTemplate,
+			Message:  err.Error(),
+		}
+	}
+	return text, t, nil
+}
+
+// generateFiles reads the schema from paths and writes the models of every table found.
+// Problems are reported to diags; one broken file does not keep the others
+// from being generated.
+func generateFiles(cfg *Config, paths []string, workers int, force, quiet bool, diags *Diagnostics) {
+	schema := readSchema(cfg, paths, workers, diags)
+	schema.setDialect(cfg.Dialect)
+	g, err := newGenerator(cfg, workers, force)
+	if err != nil {
+		diags.report(templatePath(cfg), nil, codeTemplate, err)
+		return
+	}
+	g.quiet = quiet
+	g.write(g.render(schema.models(), diags), diags)
+}
+
+// rendered is the outcome of rendering the model of one table, or one of
+// its companion files: its test or its change reader.
+type rendered struct {
+	table *Table
+	path  string
+	tmpl  *template.Template
+	// companion files are rendered from the same data as the model, whose
+	// warnings and errors are reported only once.
+	companion bool
+	input     string
+	source    []byte
+	unchanged bool
+	code      string
+	err       error
+}
+
+// render executes the templates for every table on a pool of workers.
+// Results are returned in schema order, whatever order the workers finish
+// in.
+func (g *generator) render(tables []*Table, diags *Diagnostics) []rendered {
+	relate(tables, diags)
+	var results []rendered
+	models := map[string]*Table{}
+	for _, table := range tables {
+		path, dir := outputPath(table), filepath.Clean(table.dir())
+		if other := models[dir]; other != nil {
+			// The model written last would silently replace the other, or
+			// make a second package in the directory.
+			err := fmt.Errorf("model of %s would overwrite the one of %s in %s", table.Name, other.Name, path)
+			if path != outputPath(other) {
+				err = fmt.Errorf("package %s of %s would share %s with package %s of %s", packageName(table), table.Name, table.dir(), packageName(other), other.Name)
+			}
+			results = append(results, rendered{table: table, path: path, code: codeConflict, err: err})
+			continue
+		}
+		models[dir] = table
+		results = append(results, rendered{table: table, path: path, tmpl: g.tmpl})
+		if g.testTmpl != nil {
+			results = append(results, rendered{table: table, path: testOutputPath(table), tmpl: g.testTmpl, companion: true})
+		}
+		if len(table.changeStreams) > 0 && table.Dialect == dialectPostgreSQL {
+			// The reader runs GoogleSQL's READ_ functions with named
+			// parameters, which PostgreSQL databases do not have.
+			for _, stream := range table.changeStreams {
+				diags.warnf(table.Source, table.Pos, codeSyntax, "change stream %s: no reader is generated for PostgreSQL table %s", stream.name, table.Name)
+			}
+		} else if len(table.changeStreams) > 0 && !table.view {
+			results = append(results, rendered{table: table, path: changesOutputPath(table), tmpl: g.changesTmpl, companion: true})
+		}
+	}
+	forEach(len(results), g.workers, func(i int) {
+		if results[i].err == nil {
+			g.renderTable(&results[i], diags)
+		}
+	})
+	return results
+}
+
+func (g *generator) renderTable(r *rendered, diags *Diagnostics) {
+	if r.companion {
+		// Warnings about the table were already reported for its model.
+		diags = &Diagnostics{}
+	}
+
+	data, err := newStructTemplateData(r.table, g.types, diags)
+	if err != nil {
+		r.code, r.err = codeModule, err
+		return
+	}
+	data.Validate = g.validate
+	data.OmitDefaults = g.omitDefaults
+	if key := g.keys[r.table.qualifiedName()]; key != "" && !r.table.view {
+		g.setKeyGenerator(r.table, &data, key, diags)
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		r.code, r.err = codeTemplate, err
+		return
+	}
+	r.input = hashOf([]byte(g.salt), dataJSON)
+	if !g.force && g.cache.fresh(r.path, r.input) {
+		r.unchanged = true
+		return
+	}
+
+	var output bytes.Buffer
+	if err := r.tmpl.Execute(&output, data); err != nil {
+		r.code, r.err = codeTemplate, fmt.Errorf("executing template: %w", err)
+		return
+	}
+
+	r.source, err = format.Source(output.Bytes())
+	if err != nil {
+		r.code, r.err = codeFormat, fmt.Errorf("formatting output: %w", err)
+	}
+}
+
+// setKeyGenerator makes data fill in empty keys with UUIDs, if the last
+// key column is a STRING NOT NULL.
+func (g *generator) setKeyGenerator(table *Table, data *StructTemplateData, key string, diags *Diagnostics) {
+	for _, field := range data.Fields {
+		if field.Snake != data.ID {
+			continue
+		}
+		if field.Type != "string" {
+			diags.warnf(table.Source, table.Pos, codeConfig, "keys[%q]: key column %s is not a STRING NOT NULL, ignoring %s", table.qualifiedName(), data.ID, key)
+			return
+		}
+		data.KeyGenerator, data.KeyField = key, field.Name
+		data.addStdImport(`"crypto/rand"`)
+		if key == keyUUID7 {
+			data.addStdImport(`"time"`)
+		}
+		return
+	}
+}
+
+// write writes every changed file in order, records it in the cache and
+// reports the failures of all the others to diags.
+func (g *generator) write(results []rendered, diags *Diagnostics) {
+	written, models := 0, 0
+	for _, r := range results {
+		if !r.companion {
+			models++
+		}
+		if r.err != nil {
+			if r.companion && r.code == codeModule {
+				continue // already reported for the model
+			}
+			diags.report(r.table.Source, r.table.Pos, r.code, r.err)
+			continue
+		}
+		if r.unchanged {
+			continue
+		}
+		if !g.quiet && !r.companion {
+			for _, column := range r.table.Columns {
+				log.Printf("Found -> Column: %s, Type: %s\n", column.Name, column.sqlType())
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+			diags.report(r.path, nil, codeIO, err)
+			continue
+		}
+		if err := os.WriteFile(r.path, r.source, 0644); err != nil {
+			diags.report(r.path, nil, codeIO, err)
+			continue
+		}
+		g.cache.put(r.path, r.input, r.source)
+		if g.quiet {
+			log.Printf("Generated %s\n", r.path)
+		}
+		if !r.companion {
+			written++
+		}
+	}
+	if !g.quiet {
+		log.Printf("Generated %d of %d models\n", written, models)
+	}
+
+	if err := g.cache.save(cacheFile); err != nil {
+		diags.report(cacheFile, nil, codeIO, err)
+	}
+}
+
+// forEach calls fn with every index below n on up to workers goroutines.
+func forEach(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,1179 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// renderSQL renders the models of the .sql files among files, written
+// into a new module and parsed in the order of their paths, and returns the generated code by slash-separated
+// path. Failed models are reported to the diagnostics as write would.
+func renderSQL(t *testing.T, cfg *Config, files map[string]string) (map[string]string, *Diagnostics) {
+	t.Helper()
+	files["go.mod"] = "module example.com/app\n"
+	dir := writeFiles(t, files)
+	var paths []string
+	for name := range files {
+		if strings.HasSuffix(name, ".sql") {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+	}
+	sort.Strings(paths)
+
+	diags := &Diagnostics{}
+	schema := parseSchema(paths, 1, diags)
+	schema.setDialect(cfg.Dialect)
+	g, err := newGenerator(cfg, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]string{}
+	for _, r := range g.render(schema.models(), diags) {
+		if r.err != nil {
+			diags.report(r.table.Source, r.table.Pos, r.code, r.err)
+			continue
+		}
+		abs, _ := filepath.Abs(r.path)
+		rel, _ := filepath.Rel(dir, abs)
+		out[filepath.ToSlash(rel)] = string(r.source)
+	}
+	checkPackages(t, out)
+	return out, diags
+}
+
+// hasDiagnostic reports whether diags has a diagnostic with code whose
+// message contains text.
+func hasDiagnostic(diags *Diagnostics, code, text string) bool {
+	for _, d := range diags.list {
+		if d.Code == code && strings.Contains(d.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdImporter imports the standard library from source, once for all
+// tests.
+var (
+	stdFset     = token.NewFileSet()
+	stdImporter = importer.ForCompiler(stdFset, "source", nil)
+)
+
+// packageChecker type-checks generated packages. They import each other
+// by their paths in the module of renderSQL; other packages outside the
+// standard library are stubbed as empty, and what the code uses of them
+// is left unchecked.
+type packageChecker struct {
+	out     map[string]string
+	checked map[string]*types.Package
+	errs    []error
+}
+
+func (c *packageChecker) Import(importPath string) (*types.Package, error) {
+	if dir, ok := c.generated(importPath); ok {
+		if pkg := c.check(dir); pkg != nil {
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("%s does not compile or imports itself", importPath)
+	}
+	if !c.stubbed(importPath) {
+		return stdImporter.Import(importPath)
+	}
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// generated returns the directory of importPath if code was generated
+// into it.
+func (c *packageChecker) generated(importPath string) (string, bool) {
+	dir, ok := strings.CutPrefix(importPath, "example.com/app/")
+	for name := range c.out {
+		if ok && path.Dir(name) == dir {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+func (c *packageChecker) stubbed(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	_, generated := c.generated(importPath)
+	return strings.Contains(first, ".") && !generated
+}
+
+// check type-checks the files generated into dir as one package.
+func (c *packageChecker) check(dir string) *types.Package {
+	if pkg, ok := c.checked[dir]; ok {
+		return pkg
+	}
+	c.checked[dir] = nil // an import cycle fails the check
+	var files []*ast.File
+	stubs := map[string]bool{} // names of the stubbed packages
+	names := make([]string, 0, len(c.out))
+	for name := range c.out {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if path.Dir(name) != dir || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(stdFset, name, c.out[name], parser.DeclarationErrors)
+		if err != nil {
+			c.errs = append(c.errs, err)
+			return nil
+		}
+		files = append(files, f)
+		for _, spec := range f.Imports {
+			if importPath, _ := strconv.Unquote(spec.Path.Value); c.stubbed(importPath) {
+				stubs[path.Base(importPath)] = true
+				if spec.Name != nil {
+					stubs[spec.Name.Name] = true
+				}
+			}
+		}
+	}
+	conf := types.Config{Importer: c, Error: func(err error) {
+		// Members of stubbed packages are undefined.
+		name, ok := strings.CutPrefix(err.(types.Error).Msg, "undefined: ")
+		if pkg, _, _ := strings.Cut(name, "."); ok && stubs[pkg] {
+			return
+		}
+		c.errs = append(c.errs, err)
+	}}
+	pkg, _ := conf.Check("example.com/app/"+dir, stdFset, files, nil)
+	c.checked[dir] = pkg
+	return pkg
+}
+
+// typeCheck type-checks the generated code, the files of each directory
+// as one package, and returns the errors.
+func typeCheck(out map[string]string) []error {
+	c := &packageChecker{out: out, checked: map[string]*types.Package{}}
+	dirs := map[string]bool{}
+	for name := range out {
+		dirs[path.Dir(name)] = true
+	}
+	for _, dir := range sortedKeys(dirs) {
+		c.check(dir)
+	}
+	return c.errs
+}
+
+// checkPackages fails the test if the generated code does not compile.
+func checkPackages(t *testing.T, out map[string]string) {
+	t.Helper()
+	for _, err := range typeCheck(out) {
+		t.Errorf("generated code does not compile: %v", err)
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		out  map[string]string
+		want string
+	}{
+		{
+			name: "two packages in a directory",
+			out: map[string]string{
+				"models/accounts/accounts.go":       "package accounts\n",
+				"models/accounts/sales_accounts.go": "package sales_accounts\n",
+			},
+			want: "package sales_accounts; expected package accounts",
+		},
+		{
+			name: "type error",
+			out: map[string]string{
+				"models/users/users.go": "package users\n\nimport \"fmt\"\n\nvar n int = fmt.Sprint(1)\n",
+			},
+			want: "cannot use fmt.Sprint(1)",
+		},
+		{
+			name: "name declared twice",
+			out: map[string]string{
+				"models/users/users.go": "package users\n\ntype P struct{}\n\nconst P = 1\n",
+			},
+			want: "P redeclared",
+		},
+		{
+			name: "missing member of a generated package",
+			out: map[string]string{
+				"models/users/users.go":   "package users\n\nfunc Scan() {}\n",
+				"models/orders/orders.go": "package orders\n\nimport \"example.com/app/models/users\"\n\nvar _ = users.ScanRow\n",
+			},
+			want: "undefined: users.ScanRow",
+		},
+		{
+			name: "stubbed packages",
+			out: map[string]string{
+				"models/users/users.go": "package users\n\nimport (\n\t\"cloud.google.com/go/spanner\"\n\t\"example.com/app/m_options\"\n)\n\nvar _ = spanner.Key{}\n\nvar _ m_options.Options\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range typeCheck(tt.out) {
+				got = append(got, err.Error())
+			}
+			if tt.want == "" && got != nil || tt.want != "" && !strings.Contains(strings.Join(got, "\n"), tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDuplicateOutput(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/users/schema.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE TABLE admins (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+	})
+	if !hasDiagnostic(diags, codeConflict, "model of admins would overwrite the one of users in") {
+		t.Errorf("no conflict reported:\n%s", diagnosticsText(diags))
+	}
+	if src := out["models/users/users.go"]; !regexp.MustCompile(`Table\s+= "users"`).MatchString(src) {
+		t.Errorf("users.go is not the model of users:\n%s", src)
+	}
+	if len(out) != 1 {
+		t.Errorf("generated %d files, want 1", len(out))
+	}
+}
+
+func TestRenderStructColumns(t *testing.T) {
+	for _, nullable := range []string{nullableSpanner, nullablePointer, nullableGeneric} {
+		out, diags := renderSQL(t, &Config{Nullable: nullable}, map[string]string{
+			"models/f/schema.sql": `
+CREATE TABLE f (
+  id STRING(36) NOT NULL,
+  p STRUCT<>,
+  point STRUCT<x FLOAT64, y FLOAT64, label STRUCT<text STRING(MAX)>>,
+  items ARRAY<STRUCT<name STRING(MAX), qty INT64>>,
+) PRIMARY KEY (id);
+`,
+		})
+		if diags.hasErrors() {
+			t.Fatalf("%s: unexpected errors:\n%s", nullable, diagnosticsText(diags))
+		}
+		src := out["models/f/f.go"]
+		for _, decl := range []string{"type PValue struct", "type PointValue struct", "type PointLabelValue struct", "type ItemsItemValue struct"} {
+			if !strings.Contains(src, decl) {
+				t.Errorf("%s: f.go does not declare %s", nullable, decl)
+			}
+		}
+		if !regexp.MustCompile(`\bP\s+Field = "p"`).MatchString(src) {
+			t.Errorf("%s: f.go does not declare the field P", nullable)
+		}
+	}
+}
+
+func TestRenderNameConflicts(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/f/schema.sql": `
+CREATE TABLE f (
+  id STRING(36) NOT NULL,
+  p STRUCT<a INT64>,
+  p_value STRING(MAX),
+  data STRING(MAX),
+) PRIMARY KEY (id);
+`,
+		"models/g/schema.sql": `
+CREATE TABLE g (
+  id STRING(36) NOT NULL,
+  p STRUCT<a INT64>,
+) PRIMARY KEY (id);
+`,
+	})
+	for _, msg := range []string{
+		"table f: the field of column p_value and the struct type of column p are both named PValue",
+		"table f: the field of column data and the Data of the template are both named Data",
+	} {
+		if !hasDiagnostic(diags, codeConflict, msg) {
+			t.Errorf("missing %q in:\n%s", msg, diagnosticsText(diags))
+		}
+	}
+	if _, ok := out["models/f/f.go"]; ok {
+		t.Error("f.go was generated despite its conflicts")
+	}
+	if _, ok := out["models/g/g.go"]; !ok {
+		t.Error("g.go was not generated")
+	}
+}
+
+func TestRenderInlineCheckEnum(t *testing.T) {
+	// The example of the README.
+	out, diags := renderSQL(t, &Config{Validate: true}, map[string]string{
+		"models/users/schema.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  status STRING(16) NOT NULL CHECK (status IN ('active', 'in-review')),
+  age INT64 NOT NULL CHECK (age >= 0),
+) PRIMARY KEY (id);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	src := out["models/users/users.go"]
+	for _, want := range []string{"type StatusValue string", `StatusActive   StatusValue = "active"`, `StatusInReview StatusValue = "in-review"`, `Status StatusValue`} {
+		if !strings.Contains(src, want) {
+			t.Errorf("users.go does not contain %s", want)
+		}
+	}
+	if !regexp.MustCompile(`Age:\s+\{checks: \[\]check\{\{">=", int64\(0\)\}\}\}`).MatchString(src) {
+		t.Errorf("Validate does not check age >= 0")
+	}
+}
+
+func TestRenderEnumConflicts(t *testing.T) {
+	_, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/a/schema.sql": `
+CREATE TABLE a (
+  id STRING(36) NOT NULL,
+  status STRING(16) NOT NULL CHECK (status IN ('in-review', 'in_review')),
+) PRIMARY KEY (id);
+`,
+		"models/b/schema.sql": `
+CREATE TABLE b (
+  id STRING(36) NOT NULL,
+  status STRING(16) NOT NULL CHECK (status IN ('active', 'in-review')),
+  status_in_review BOOL,
+) PRIMARY KEY (id);
+`,
+	})
+	for _, msg := range []string{
+		`table a: the constant of value "in_review" of column status and the constant of value "in-review" of column status are both named StatusInReview`,
+		`table b: the field of column status_in_review and the constant of value "in-review" of column status are both named StatusInReview`,
+	} {
+		if !hasDiagnostic(diags, codeConflict, msg) {
+			t.Errorf("missing %q in:\n%s", msg, diagnosticsText(diags))
+		}
+	}
+}
+
+func TestRenderTableNames(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/orders/schema.sql":     `CREATE TABLE "Orders" (id bigint PRIMARY KEY, total numeric);`,
+		"models/orderitems/schema.sql": `CREATE TABLE OrderItems (id bigint PRIMARY KEY);`,
+		"models/singers/schema.sql":    "CREATE TABLE SingerAlbums (Id INT64 NOT NULL) PRIMARY KEY (Id);",
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	for path, table := range map[string]string{
+		"models/orders/orders.go":         "Orders",
+		"models/orderitems/orderitems.go": "orderitems",
+		"models/singers/singers.go":       "singer_albums",
+	} {
+		if !regexp.MustCompile(`Table\s+= "` + table + `"`).MatchString(out[path]) {
+			t.Errorf("%s: Table is not %q", path, table)
+		}
+	}
+}
+
+func TestRenderViewNextToTable(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/users/schema.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  active BOOL NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE VIEW active_users SQL SECURITY INVOKER AS SELECT users.id FROM users WHERE users.active;
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	users, view := out["models/users/users.go"], out["models/users/activeusers/activeusers.go"]
+	if !regexp.MustCompile(`Table\s+= "users"`).MatchString(users) {
+		t.Errorf("users.go is not the model of users:\n%s", users)
+	}
+	if !strings.HasPrefix(view, "package activeusers") || !regexp.MustCompile(`Table\s+= "active_users"`).MatchString(view) {
+		t.Errorf("activeusers.go is not the model of active_users:\n%s", view)
+	}
+}
+
+func TestRenderKeyTypes(t *testing.T) {
+	out, diags := renderSQL(t, &Config{Keys: map[string]string{"tickets": keyUUID7}}, map[string]string{
+		"models/events/schema.sql": `
+CREATE SEQUENCE event_ids OPTIONS (sequence_kind = 'bit_reversed_positive');
+CREATE TABLE events (
+  id INT64 NOT NULL DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE event_ids)),
+  kind STRING(MAX),
+) PRIMARY KEY (id);
+`,
+		"models/tickets/schema.sql": `
+CREATE TABLE tickets (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	events := out["models/events/events.go"]
+	for _, method := range []string{"Exists", "Find", "Update", "Delete"} {
+		sig := regexp.MustCompile(`func \(c \*Facade\) ` + method + `\(\s*ctx context.Context,\s*id int64,`)
+		if !sig.MatchString(events) {
+			t.Errorf("%s does not take id as an int64", method)
+		}
+	}
+
+	tickets := out["models/tickets/tickets.go"]
+	if strings.Contains(tickets, "panic(") {
+		t.Error("tickets.go panics")
+	}
+	for _, want := range []string{"func (data *Data) SetKey() error", "func newKey() (string, error)", "if err := data.SetKey(); err != nil {"} {
+		if !strings.Contains(tickets, want) {
+			t.Errorf("tickets.go does not contain %s", want)
+		}
+	}
+}
+
+func TestRenderRelations(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/assistants/schema.sql": `
+CREATE TABLE assistants (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+) PRIMARY KEY (id);
+`,
+		"models/resources/schema.sql": `
+CREATE TABLE resources (
+  id STRING(36) NOT NULL,
+  assistant_id STRING(36),
+  CONSTRAINT fk_assistant FOREIGN KEY (assistant_id) REFERENCES assistants (id),
+) PRIMARY KEY (id);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	resources := out["models/resources/resources.go"]
+	for _, want := range []string{`"example.com/app/models/assistants"`, "func (c *Facade) LoadAssistant(", "func (c *Facade) LoadAssistantBatch(", "func (c *Facade) ListResourcesByAssistant("} {
+		if !strings.Contains(resources, want) {
+			t.Errorf("resources.go does not contain %s", want)
+		}
+	}
+	if !strings.Contains(out["models/assistants/assistants.go"], "func ScanRow(") {
+		t.Error("assistants.go does not declare ScanRow")
+	}
+}
+
+func TestRenderRelationInSamePackage(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/resources/schema.sql": `
+CREATE TABLE resources (
+  id STRING(36) NOT NULL,
+  assistant_id STRING(36),
+  CONSTRAINT fk_assistant FOREIGN KEY (assistant_id) REFERENCES assistants (id),
+) PRIMARY KEY (id);
+
+CREATE TABLE assistants (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+	})
+	if !hasDiagnostic(diags, codeUnknownRef, "table resources: foreign key on assistant_id references assistants in the same package, no loaders generated") {
+		t.Errorf("same package not reported:\n%s", diagnosticsText(diags))
+	}
+	resources, ok := out["models/resources/resources.go"]
+	if !ok {
+		t.Fatalf("resources.go was not generated:\n%s", diagnosticsText(diags))
+	}
+	if strings.Contains(resources, "LoadAssistant") {
+		t.Error("resources.go has loaders of a table in the same package")
+	}
+}
+
+func TestRenderSchemaTableNextToTable(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/accounts/schema.sql": `
+CREATE SCHEMA sales;
+
+CREATE TABLE sales.accounts (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE TABLE accounts (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	accounts, sales := out["models/accounts/accounts.go"], out["models/accounts/sales/accounts/sales_accounts.go"]
+	if !strings.HasPrefix(accounts, "package accounts") || !regexp.MustCompile(`Table\s+= "accounts"`).MatchString(accounts) {
+		t.Errorf("accounts.go is not the model of accounts:\n%s", accounts)
+	}
+	if !strings.HasPrefix(sales, "package sales_accounts") || !regexp.MustCompile(`Table\s+= "sales.accounts"`).MatchString(sales) {
+		t.Errorf("sales_accounts.go is not the model of sales.accounts:\n%s", sales)
+	}
+	if len(out) != 2 {
+		t.Errorf("generated %d files, want 2", len(out))
+	}
+}
+
+func TestRenderSharedPackageDirectory(t *testing.T) {
+	// notes is parsed first and takes the directory sales.accounts
+	// would move to.
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/accounts/schema.sql": `
+CREATE SCHEMA sales;
+
+CREATE TABLE sales.accounts (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE TABLE accounts (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+		"models/accounts/sales/accounts/schema.sql": `
+CREATE TABLE notes (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+`,
+	})
+	if !hasDiagnostic(diags, codeConflict, "package sales_accounts of accounts would share") || !hasDiagnostic(diags, codeConflict, "sales/accounts with package accounts of notes") {
+		t.Errorf("no conflict reported:\n%s", diagnosticsText(diags))
+	}
+	if notes := out["models/accounts/sales/accounts/accounts.go"]; !regexp.MustCompile(`Table\s+= "notes"`).MatchString(notes) {
+		t.Errorf("accounts.go is not the model of notes:\n%s", notes)
+	}
+	if len(out) != 2 {
+		t.Errorf("generated %d files, want 2", len(out))
+	}
+}
+
+func TestRenderPostgreSQLColumnNames(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/accounts/schema.sql": `
+CREATE TABLE Accounts (
+  AccountId varchar(36) NOT NULL PRIMARY KEY,
+  "DisplayName" text
+);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	src := out["models/accounts/accounts.go"]
+	for _, want := range []string{`Table\s+= "accounts"`, `ID\s+= "accountid"`, `Accountid\s+Field = "accountid"`, `Displayname\s+Field = "DisplayName"`, `"accountid":\s+accountid`} {
+		if !regexp.MustCompile(want).MatchString(src) {
+			t.Errorf("accounts.go does not match %s", want)
+		}
+	}
+	if strings.Contains(src, `"AccountId"`) {
+		t.Error("accounts.go uses the unfolded name AccountId")
+	}
+}
+
+func TestRenderChangeReader(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/users/schema.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+) PRIMARY KEY (id);
+
+CREATE CHANGE STREAM UsersCdc FOR users(name);
+CREATE CHANGE STREAM AllCdc FOR ALL;
+CREATE CHANGE STREAM UserKeys FOR users();
+`,
+		"models/accounts/schema.sql": `CREATE TABLE accounts (id varchar(36) PRIMARY KEY);`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	changes := out["models/users/users_changes.go"]
+	if !strings.HasPrefix(changes, "package users") {
+		t.Fatalf("users_changes.go is not in package users:\n%s", changes)
+	}
+	for _, want := range []string{
+		`ChangeStreamUsersCdc = "UsersCdc"`,
+		`ChangeStreamUsersCdc records the key and name\.`,
+		`ChangeStreamAllCdc\s+= "AllCdc"`,
+		`ChangeStreamAllCdc records all columns\.`,
+		`ChangeStreamUserKeys records only the key\.`,
+		`const changeTable = "users"`,
+		`func \(c \*Facade\) ChangeReader\(stream string, checkpoint Checkpoint\) \*ChangeReader`,
+		`FROM READ_%s\(start_timestamp => @start`,
+	} {
+		if !regexp.MustCompile(want).MatchString(changes) {
+			t.Errorf("users_changes.go does not match %s", want)
+		}
+	}
+
+	// The reader only speaks GoogleSQL.
+	if _, ok := out["models/accounts/accounts_changes.go"]; ok {
+		t.Error("a change reader was generated for a PostgreSQL table")
+	}
+	if !hasDiagnostic(diags, codeSyntax, "change stream AllCdc: no reader is generated for PostgreSQL table accounts") {
+		t.Errorf("PostgreSQL table not reported:\n%s", diagnosticsText(diags))
+	}
+	if _, ok := out["models/accounts/accounts.go"]; !ok {
+		t.Error("accounts.go was not generated")
+	}
+}
+
+func TestRenderCodecDecodesOnlyStrings(t *testing.T) {
+	uuid := TypeMapping{Type: "uuid.UUID", Import: "github.com/google/uuid", Codec: codecText}
+	out, diags := renderSQL(t, &Config{Types: map[string]TypeMapping{"STRING(36)": uuid}}, map[string]string{
+		"models/users/schema.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  ref STRING(36),
+) PRIMARY KEY (id);
+`,
+	})
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	src := out["models/users/users.go"]
+	decode := regexp.MustCompile(`(?s)func \(c refCodec\) DecodeSpanner\(input interface\{\}\) error \{\n(.*?)\n\}`).FindStringSubmatch(src)
+	if decode == nil {
+		t.Fatalf("users.go has no refCodec.DecodeSpanner:\n%s", src)
+	}
+	for _, want := range []string{
+		"if p, isString := input.(*string); input == nil || isString && p == nil {",
+		`return fmt.Errorf("%s: cannot decode %T", Ref, input)`,
+	} {
+		if !strings.Contains(decode[1], want) {
+			t.Errorf("refCodec.DecodeSpanner does not contain %s:\n%s", want, decode[1])
+		}
+	}
+}
+
+func TestRenderStrict(t *testing.T) {
+	const sql = `
+CREATE TABLE places (
+  id STRING(36) NOT NULL,
+  area GEOGRAPHY,
+) PRIMARY KEY (id);
+`
+	on, off := true, false
+	tests := []struct {
+		name     string
+		cfg      *Config
+		ci       string
+		severity Severity
+	}{
+		{name: "off", cfg: &Config{Strict: &off}, ci: "true", severity: SeverityWarning},
+		{name: "on", cfg: &Config{Strict: &on}, severity: SeverityError},
+		{name: "ci", cfg: &Config{}, ci: "true", severity: SeverityError},
+		{name: "not ci", cfg: &Config{}, severity: SeverityWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CI", tt.ci)
+			out, diags := renderSQL(t, tt.cfg, map[string]string{"models/places/schema.sql": sql})
+			if len(diags.list) != 1 {
+				t.Fatalf("diagnostics:\n%s", diagnosticsText(diags))
+			}
+			d := diags.list[0]
+			if d.Code != codeUnknownType || d.Severity != tt.severity || d.Line != 4 || filepath.Base(d.File) != "schema.sql" {
+				t.Errorf("diagnostic = %s:%d %s %s %s", d.File, d.Line, d.Severity, d.Code, d.Message)
+			}
+			src, ok := out["models/places/places.go"]
+			if tt.severity == SeverityError {
+				if ok {
+					t.Error("places.go was generated despite the unmapped type")
+				}
+				if !strings.Contains(d.Message, `map it in "types" of model-gen.json`) {
+					t.Errorf("message = %s", d.Message)
+				}
+				return
+			}
+			if !regexp.MustCompile(`Area\s+interface\{\}`).MatchString(src) {
+				t.Errorf("area is not an interface{}:\n%s", src)
+			}
+		})
+	}
+
+	// A mapping in the config takes the type out of strict mode's way.
+	cfg := &Config{Strict: &on, Types: map[string]TypeMapping{"GEOGRAPHY": {Type: "string"}}}
+	out, diags := renderSQL(t, cfg, map[string]string{"models/places/schema.sql": sql})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	if !regexp.MustCompile(`Area\s+string`).MatchString(out["models/places/places.go"]) {
+		t.Errorf("area is not a string:\n%s", out["models/places/places.go"])
+	}
+}
+
+func TestRenderNullable(t *testing.T) {
+	const sql = `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+  age INT64,
+  born DATE,
+  counts ARRAY<INT64>,
+  scores ARRAY<INT64> NOT NULL,
+  avatar BYTES(MAX),
+  profile JSON,
+  nick STRING(40),
+) PRIMARY KEY (id);
+`
+	tests := []struct {
+		mode string
+		want map[string]string
+	}{
+		{
+			mode: nullableSpanner,
+			want: map[string]string{
+				"Id": "string", "Name": "spanner.NullString", "Age": "spanner.NullInt64", "Born": "spanner.NullDate",
+				"Counts": `\[\]spanner.NullInt64`, "Scores": `\[\]spanner.NullInt64`,
+			},
+		},
+		{
+			mode: nullablePointer,
+			want: map[string]string{
+				"Id": "string", "Name": `\*string`, "Age": `\*int64`, "Born": `\*civil.Date`,
+				"Counts": `\[\]\*int64`, "Scores": `\[\]\*int64`,
+			},
+		},
+		{
+			mode: nullableGeneric,
+			want: map[string]string{
+				"Id": "string", "Name": `Null\[string\]`, "Age": `Null\[int64\]`, "Born": `Null\[civil.Date\]`,
+				"Counts": `\[\]Null\[int64\]`, "Scores": `\[\]Null\[int64\]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := &Config{
+				Nullable: tt.mode,
+				Columns:  map[string]TypeMapping{"users.nick": {Type: "spanner.NullString"}},
+			}
+			out, diags := renderSQL(t, cfg, map[string]string{"models/users/schema.sql": sql})
+			if len(diags.list) != 0 {
+				t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+			}
+			src := out["models/users/users.go"]
+			// BYTES, JSON and configured columns are the same in every mode.
+			tt.want["Avatar"], tt.want["Profile"], tt.want["Nick"] = `\[\]byte`, "spanner.NullJSON", "spanner.NullString"
+			for field, typ := range tt.want {
+				if !regexp.MustCompile(`(?m)^\t` + field + `\s+` + typ + `$`).MatchString(src) {
+					t.Errorf("%s is not a %s:\n%s", field, typ, src)
+				}
+			}
+			if hasNull := strings.Contains(src, "type Null[T any] struct"); hasNull != (tt.mode == nullableGeneric) {
+				t.Errorf("Null[T] declared = %v", hasNull)
+			}
+		})
+	}
+}
+
+func TestRenderDateAndNumericImports(t *testing.T) {
+	out, diags := renderSQL(t, &Config{Tests: true}, map[string]string{
+		"models/orders/schema.sql": `
+CREATE TABLE orders (
+  id STRING(36) NOT NULL,
+  placed DATE NOT NULL,
+  shipped DATE,
+  total NUMERIC NOT NULL,
+  discount NUMERIC,
+  details JSON,
+) PRIMARY KEY (id);
+`,
+		"models/users/schema.sql": "CREATE TABLE users (id STRING(36) NOT NULL, name STRING(MAX)) PRIMARY KEY (id);",
+	})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	orders := out["models/orders/orders.go"]
+	for _, want := range []string{
+		`(?m)^\tPlaced\s+civil.Date$`,
+		`(?m)^\tShipped\s+spanner.NullDate$`,
+		`(?m)^\tTotal\s+big.Rat$`,
+		`(?m)^\tDiscount\s+spanner.NullNumeric$`,
+		`(?m)^\tDetails\s+spanner.NullJSON$`,
+		`(?m)^\t"cloud.google.com/go/civil"$`,
+		`(?m)^\t"math/big"$`,
+	} {
+		if !regexp.MustCompile(want).MatchString(orders) {
+			t.Errorf("orders.go does not match %s:\n%s", want, orders)
+		}
+	}
+	// Only the packages the fields use are imported.
+	users := out["models/users/users.go"]
+	for _, unused := range []string{`"cloud.google.com/go/civil"`, `"math/big"`} {
+		if strings.Contains(users, unused) {
+			t.Errorf("users.go imports %s", unused)
+		}
+	}
+
+	// The round-trip test covers the values and NULL of each column.
+	test, ok := out["models/orders/orders_test.go"]
+	if !ok {
+		t.Fatal("orders_test.go was not generated")
+	}
+	for _, want := range []string{
+		`structpb.NewStringValue("2024-01-02")`,
+		`structpb.NewStringValue("1.500000000")`,
+		"sppb.TypeCode_DATE",
+		"sppb.TypeCode_NUMERIC",
+		"structpb.NewNullValue()",
+	} {
+		if !strings.Contains(test, want) {
+			t.Errorf("orders_test.go does not contain %s:\n%s", want, test)
+		}
+	}
+}
+
+func TestRenderValidate(t *testing.T) {
+	const sql = `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(10) NOT NULL,
+  bio STRING(MAX),
+  avatar BYTES(1024),
+  tags ARRAY<STRING(20)> NOT NULL,
+  age INT64 CHECK (age >= 0 AND age < 150),
+  score FLOAT64,
+  name_upper STRING(10) AS (UPPER(name)) STORED,
+  CONSTRAINT score_range CHECK (score <= 1.5),
+  CONSTRAINT other CHECK (LENGTH(bio) > age),
+) PRIMARY KEY (id);
+`
+	for _, validate := range []bool{false, true} {
+		out, diags := renderSQL(t, &Config{Validate: validate}, map[string]string{"models/users/schema.sql": sql})
+		if len(diags.list) != 0 {
+			t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+		}
+		src := out["models/users/users.go"]
+		constraints := regexp.MustCompile(`(?s)var constraints = map\[Field\]constraint\{\n(.*?)\n\}`).FindStringSubmatch(src)
+		if constraints == nil {
+			t.Fatalf("users.go has no constraints:\n%s", src)
+		}
+		got := regexp.MustCompile(`\s+`).ReplaceAllString(strings.TrimSpace(constraints[1]), " ")
+		// A NOT NULL string cannot be nil, so only the slice must be set;
+		// the CHECK comparing two columns is left to Spanner.
+		want := `Id: {maxLength: 36}, Name: {maxLength: 10}, Avatar: {maxLength: 1024}, Tags: {notNull: true}, ` +
+			`Age: {checks: []check{{">=", int64(0)}, {"<", int64(150)}}}, Score: {checks: []check{{"<=", float64(1.5)}}}, ` +
+			`NameUpper: {maxLength: 10, generated: true},`
+		if got != want {
+			t.Errorf("constraints =\n%s\nwant\n%s", got, want)
+		}
+
+		validateBody := regexp.MustCompile(`(?s)func \(data \*Data\) Validate\(\) error \{\n(.*?)\n\}`).FindStringSubmatch(src)
+		if validateBody == nil {
+			t.Fatalf("users.go has no Data.Validate:\n%s", src)
+		}
+		for _, field := range []string{"Id", "Name", "Avatar", "Tags", "Age", "Score"} {
+			if !strings.Contains(validateBody[1], "validateField(errs, "+field+", data."+field+")") {
+				t.Errorf("Data.Validate does not check %s:\n%s", field, validateBody[1])
+			}
+		}
+		if strings.Contains(validateBody[1], "NameUpper") {
+			t.Errorf("Data.Validate checks the generated column:\n%s", validateBody[1])
+		}
+
+		for _, msg := range []string{`"must not be NULL"`, `"length %d exceeds %d"`, `"%v violates CHECK %s %s %#v"`} {
+			if !strings.Contains(src, msg) {
+				t.Errorf("validateField does not report %s", msg)
+			}
+		}
+
+		if got := strings.Count(src, "if err := data.Validate(); err != nil {"); validate && got != 2 || !validate && got != 0 {
+			t.Errorf("validate %v: Create and Update call Validate %d times", validate, got)
+		}
+	}
+}
+
+func TestRenderGeneratedAndDefaultColumns(t *testing.T) {
+	const sql = `
+CREATE TABLE users (
+  id STRING(36) NOT NULL DEFAULT (GENERATE_UUID()),
+  name STRING(10) NOT NULL,
+  created TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP()),
+  name_upper STRING(10) AS (UPPER(name)) STORED,
+  name_lower STRING(10) AS (LOWER(name)),
+) PRIMARY KEY (id);
+`
+	tests := []struct {
+		defaults        string
+		written, zeroed []string
+	}{
+		// The key column is left out while zero even when defaults are
+		// written, so that Spanner assigns it.
+		{defaults: defaultsOmitZero, written: []string{"Name"}, zeroed: []string{"Id", "Created"}},
+		{defaults: defaultsWrite, written: []string{"Name", "Created"}, zeroed: []string{"Id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.defaults, func(t *testing.T) {
+			out, diags := renderSQL(t, &Config{Defaults: tt.defaults}, map[string]string{"models/users/schema.sql": sql})
+			if len(diags.list) != 0 {
+				t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+			}
+			src := out["models/users/users.go"]
+			insert := regexp.MustCompile(`(?s)func \(data \*Data\) insertColumns\(\) \(\[\]string, \[\]interface\{\}\) \{\n(.*?)\n\}`).FindStringSubmatch(src)
+			if insert == nil {
+				t.Fatalf("users.go has no insertColumns:\n%s", src)
+			}
+			always := regexp.MustCompile(`(?s)columns := \[\]string\{(.*?)\}`).FindStringSubmatch(insert[1])
+			var written []string
+			for _, m := range regexp.MustCompile(`(\w+)\.String\(\)`).FindAllStringSubmatch(always[1], -1) {
+				written = append(written, m[1])
+			}
+			var zeroed []string
+			for _, m := range regexp.MustCompile(`if !reflect\.ValueOf\(data\.(\w+)\)\.IsZero\(\) \{`).FindAllStringSubmatch(insert[1], -1) {
+				zeroed = append(zeroed, m[1])
+			}
+			if fmt.Sprint(written) != fmt.Sprint(tt.written) || fmt.Sprint(zeroed) != fmt.Sprint(tt.zeroed) {
+				t.Errorf("insertColumns writes %q and %q unless zero, want %q and %q:\n%s", written, zeroed, tt.written, tt.zeroed, insert[1])
+			}
+			if strings.Contains(insert[1], "NameUpper") || strings.Contains(insert[1], "NameLower") {
+				t.Errorf("insertColumns writes a generated column:\n%s", insert[1])
+			}
+
+			// Updates drop generated columns, which Validate reports.
+			update := regexp.MustCompile(`(?s)func \(c \*Facade\) UpdateMut\(.*?\n\}`).FindString(src)
+			if !strings.Contains(update, "if constraints[field].generated {\n\t\t\tcontinue\n\t\t}") {
+				t.Errorf("UpdateMut writes generated columns:\n%s", update)
+			}
+			for _, want := range []string{
+				`(?m)^\tNameUpper: \{maxLength: 10, generated: true\},$`,
+				`(?m)^\tNameLower: \{maxLength: 10, generated: true\},$`,
+			} {
+				if !regexp.MustCompile(want).MatchString(src) {
+					t.Errorf("users.go does not match %s", want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPostgreSQL(t *testing.T) {
+	const sql = `
+CREATE TABLE users (
+  id bigint PRIMARY KEY,
+  name character varying(40) NOT NULL,
+  bio text,
+  score double precision,
+  avatar bytea,
+  created timestamptz NOT NULL,
+  total numeric,
+  details jsonb,
+  tags varchar(10)[]
+);
+`
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  string
+		postgres bool
+	}{
+		{name: "detected", sql: sql, postgres: true},
+		{name: "configured", sql: "CREATE TABLE users (id INT64 NOT NULL) PRIMARY KEY (id);", dialect: dialectPostgreSQL, postgres: true},
+		{name: "googlesql", sql: "CREATE TABLE users (id INT64 NOT NULL) PRIMARY KEY (id);"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, diags := renderSQL(t, &Config{Dialect: tt.dialect}, map[string]string{"models/users/schema.sql": tt.sql})
+			if len(diags.list) != 0 {
+				t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+			}
+			src := out["models/users/users.go"]
+			pgGet := []string{
+				`whereClause := fmt.Sprintf("%s %s $%d", quoteIdent(qp.Field.String()), qp.Operator, i+1)`,
+				`whereClause = fmt.Sprintf("%s = ANY($%d)", quoteIdent(qp.Field.String()), i+1)`,
+				"parts[i] = `\"` + strings.ReplaceAll(part, `\"`, `\"\"`) + `\"`",
+			}
+			for _, want := range pgGet {
+				if got := strings.Contains(src, want); got != tt.postgres {
+					t.Errorf("users.go contains %s: %v", want, got)
+				}
+			}
+		})
+	}
+
+	out, _ := renderSQL(t, &Config{}, map[string]string{"models/users/schema.sql": sql})
+	src := out["models/users/users.go"]
+	for field, typ := range map[string]string{
+		"Id":      "int64",
+		"Name":    "string",
+		"Bio":     "spanner.NullString",
+		"Score":   "spanner.NullFloat64",
+		"Avatar":  `\[\]byte`,
+		"Created": `time.Time`,
+		"Total":   "spanner.PGNumeric",
+		"Details": "spanner.PGJsonB",
+		"Tags":    `\[\]spanner.NullString`,
+	} {
+		if !regexp.MustCompile(`(?m)^\t` + field + `\s+` + typ + `$`).MatchString(src) {
+			t.Errorf("%s is not a %s:\n%s", field, typ, src)
+		}
+	}
+}
+
+func TestRenderSearch(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/docs/schema.sql": `
+CREATE TABLE docs (
+  id STRING(36) NOT NULL,
+  title STRING(MAX) NOT NULL,
+  body STRING(MAX),
+  title_tokens TOKENLIST AS (TOKENIZE_FULLTEXT(title)) HIDDEN,
+  body_tokens TOKENLIST AS (TOKENIZE_FULLTEXT(body)) HIDDEN,
+  id_tokens TOKENLIST AS (TOKEN(id)) HIDDEN,
+) PRIMARY KEY (id);
+
+CREATE SEARCH INDEX docs_search ON docs(title_tokens, body_tokens, id_tokens);
+`,
+		"models/notes/schema.sql": `
+CREATE TABLE notes (
+  id STRING(36) NOT NULL,
+  text STRING(MAX),
+  text_tokens TOKENLIST AS (TOKENIZE_FULLTEXT(text)) HIDDEN,
+) PRIMARY KEY (id);
+`,
+	})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	docs := out["models/docs/docs.go"]
+	data := regexp.MustCompile(`(?s)type Data struct \{\n(.*?)\n\}`).FindStringSubmatch(docs)
+	if data == nil || strings.Contains(data[1], "Tokens") {
+		t.Errorf("TOKENLIST columns are in Data:\n%s", docs)
+	}
+	// Only the full-text columns of the index are searched, and snippets
+	// are taken from the columns they tokenize.
+	for _, want := range []string{
+		`(?m)^\tSearchTitleTokens SearchColumn = "title_tokens"$`,
+		`(?m)^\tSearchBodyTokens  SearchColumn = "body_tokens"$`,
+		`(?m)^\tSearchTitleTokens: Title,$`,
+		`(?m)^\tSearchBodyTokens:  Body,$`,
+		`searched = \[\]SearchColumn\{\n\t\t\tSearchTitleTokens,\n\t\t\tSearchBodyTokens,\n\t\t\}`,
+		`matches = append\(matches, fmt.Sprintf\("SEARCH\(%s, @query\)", quoteIdent\(string\(column\)\)\)\)`,
+		`scores = append\(scores, fmt.Sprintf\("SCORE\(%s, @query\)", quoteIdent\(string\(column\)\)\)\)`,
+		`columns = append\(columns, strings.Join\(scores, " \+ "\)\+" AS _score"\)`,
+		`fmt.Sprintf\("SNIPPET\(%s, @query\) AS _snippet%d", quoteIdent\(searchSources\[column\].String\(\)\), i\)`,
+		`fmt.Sprintf\("SELECT %s FROM %s WHERE %s ORDER BY _score DESC",\n\t\tstrings.Join\(columns, ", "\), quoteIdent\(Table\), strings.Join\(matches, " OR "\)\)`,
+		`queryString \+= " LIMIT @limit"`,
+	} {
+		if !regexp.MustCompile(want).MatchString(docs) {
+			t.Errorf("docs.go does not match %s", want)
+		}
+	}
+	if strings.Contains(docs, "IdTokens") {
+		t.Error("the TOKEN column is searched")
+	}
+	// Without a search index there is nothing to search.
+	if notes := out["models/notes/notes.go"]; strings.Contains(notes, "func (c *Facade) Search(") {
+		t.Error("notes without a search index can be searched")
+	}
+}
+
+func TestRenderNearestNeighbors(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/chunks/schema.sql": `
+CREATE TABLE chunks (
+  id STRING(36) NOT NULL,
+  lang STRING(8),
+  embedding ARRAY<FLOAT32>(vector_length=>3),
+  title_vec ARRAY<FLOAT64>(vector_length=>2) NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE VECTOR INDEX chunks_embedding ON chunks(embedding)
+WHERE embedding IS NOT NULL OPTIONS (distance_type = 'COSINE', num_leaves = 1000);
+`,
+		"models/images/schema.sql": `
+CREATE TABLE images (
+  id STRING(36) NOT NULL,
+  embedding ARRAY<FLOAT32>(vector_length=>4),
+) PRIMARY KEY (id);
+`,
+	})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	chunks := out["models/chunks/chunks.go"]
+	for _, want := range []string{
+		`(?m)^\tEmbedding\s+\[\]float32$`,
+		`(?m)^\tTitleVec\s+\[\]float64$`,
+		`(?m)^\tEmbedding: \{vectorLength: 3\},$`,
+		`(?m)^\tTitleVec:  \{notNull: true, vectorLength: 2\},$`,
+		`var vectorFields = \[\]Field\{Embedding, TitleVec\}`,
+		// Several embeddings: a method for each, checking the length.
+		`func \(c \*Facade\) NearestNeighborsEmbedding\(\n\tctx context.Context,\n\tvec \[\]float32,`,
+		`func \(c \*Facade\) NearestNeighborsTitleVec\(\n\tctx context.Context,\n\tvec \[\]float64,`,
+		`if len\(vec\) != 3 \{\n\t\treturn nil, fmt.Errorf\("NearestNeighborsEmbedding: vector has %d dimensions, want 3", len\(vec\)\)`,
+		`if len\(vec\) != 2 \{\n\t\treturn nil, fmt.Errorf\("NearestNeighborsTitleVec: vector has %d dimensions, want 2", len\(vec\)\)`,
+		`DistanceCosine:     "COSINE_DISTANCE",\n\tDistanceEuclidean:  "EUCLIDEAN_DISTANCE",\n\tDistanceDotProduct: "DOT_PRODUCT",`,
+		// The index's distance uses it, searching 1% of its leaves.
+		`from \+= "@\{FORCE_INDEX=chunks_embedding\}"\n\t\tdistanceExpr = fmt.Sprintf\(` + "`" + `APPROX_%s\(%s, @vec, options => JSON '\{"num_leaves_to_search": 10\}'\)` + "`",
+		`order := "ASC"\n\tif distance == DistanceDotProduct \{\n\t\torder = "DESC"`,
+		`"SELECT %s, %s AS _distance FROM %s WHERE %s ORDER BY _distance %s LIMIT @k"`,
+		`whereClauses := \[\]string\{quoteIdent\(Embedding.String\(\)\) \+ " IS NOT NULL"\}`,
+	} {
+		if !regexp.MustCompile(want).MatchString(chunks) {
+			t.Errorf("chunks.go does not match %s", want)
+		}
+	}
+	// title_vec has no index: every search is exact.
+	titleVec := regexp.MustCompile(`(?s)func \(c \*Facade\) NearestNeighborsTitleVec\(.*?\n\}`).FindString(chunks)
+	if strings.Contains(titleVec, "APPROX_") || strings.Contains(titleVec, "FORCE_INDEX") {
+		t.Errorf("NearestNeighborsTitleVec uses an index:\n%s", titleVec)
+	}
+
+	// A single embedding gets an unsuffixed method.
+	images := out["models/images/images.go"]
+	if !strings.Contains(images, "func (c *Facade) NearestNeighbors(\n") || strings.Contains(images, "NearestNeighborsEmbedding") {
+		t.Errorf("images.go has no NearestNeighbors:\n%s", images)
+	}
+}
+
+func TestRenderDeletionPolicy(t *testing.T) {
+	out, diags := renderSQL(t, &Config{}, map[string]string{
+		"models/tickets/schema.sql": `
+CREATE TABLE tickets (
+  id STRING(36) NOT NULL,
+  created TIMESTAMP,
+) PRIMARY KEY (id), ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 DAY));
+`,
+		"models/users/schema.sql": "CREATE TABLE users (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+	})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	tickets := out["models/tickets/tickets.go"]
+	for _, want := range []string{
+		`(?m)^\tExpiryField = Created$`,
+		`(?m)^\tRetention   = 30 \* 24 \* time.Hour$`,
+		`func ExpiresAt\(data \*Data\) \(time.Time, bool\) \{\n\tv, ok := plainValue\(data.Created\)\n\tif !ok \{\n\t\treturn time.Time\{\}, false`,
+		`return t.Add\(Retention\), true`,
+		`func NotExpired\(\) QueryParam \{\n\treturn QueryParam\{Field: ExpiryField, Operator: ">", Value: time.Now\(\).Add\(-Retention\)\}`,
+	} {
+		if !regexp.MustCompile(want).MatchString(tickets) {
+			t.Errorf("tickets.go does not match %s", want)
+		}
+	}
+	if users := out["models/users/users.go"]; strings.Contains(users, "Retention") || strings.Contains(users, "NotExpired") {
+		t.Error("users.go without a policy has TTL helpers")
+	}
+}
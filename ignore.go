@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is one line of a .gitignore style file.
+type ignoreRule struct {
+	base     string // directory holding the ignore file
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type ignoreRules []ignoreRule
+
+// ignored reports whether path is ignored. As in git, the last matching
+// rule wins.
+func (rules ignoreRules) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(r.base, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !r.anchored {
+			rel = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if matchGlob(r.pattern, rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func readIgnoreRules(dir string) ignoreRules {
+	var rules ignoreRules
+	for _, name := range ignoreFiles {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if r, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+				rules = append(rules, r)
+			}
+		}
+		file.Close()
+	}
+	return rules
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	r.pattern = line
+	return r, line != ""
+}
+
+// ancestorIgnoreRules collects the rules of the directories above root up
+// to the enclosing git repository, outermost first. Outside of a repository
+// there are none.
+func ancestorIgnoreRules(root string) ignoreRules {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+		return nil
+	}
+
+	var dirs []string
+	for d := filepath.Dir(root); ; d = filepath.Dir(d) {
+		dirs = append(dirs, d)
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(d) == d {
+			return nil
+		}
+	}
+
+	var rules ignoreRules
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules = append(rules, readIgnoreRules(dirs[i])...)
+	}
+	return rules
+}
@@ -1,26 +1,29 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"errors"
+	"flag"
 	"fmt"
-	"go/format"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"sort"
 	"strings"
-	"text/template"
 )
 
 var spannerTypeMapping = map[string]string{
 	"INT64 NOT NULL":     "int64",
 	"STRING NOT NULL":    "string",
 	"TIMESTAMP NOT NULL": "time.Time",
-	"DATE NOT NULL":      "time.Time",
+	"DATE NOT NULL":      "civil.Date",
 	"BOOL NOT NULL":      "bool",
 	"FLOAT64 NOT NULL":   "float64",
+	"FLOAT32 NOT NULL":   "float32",
 	"NUMERIC NOT NULL":   "big.Rat",
+	"JSON NOT NULL":      "spanner.NullJSON",
 	"INT64":              "spanner.NullInt64",
 	"STRING":             "spanner.NullString",
 	"BYTES":              "[]byte",
@@ -31,39 +34,118 @@ var spannerTypeMapping = map[string]string{
 	"FLOAT32":            "spanner.NullFloat32",
 	"JSON":               "spanner.NullJSON",
 	"NUMERIC":            "spanner.NullNumeric",
+	"PG_NUMERIC":         "spanner.PGNumeric",
+	"PG_JSONB":           "spanner.PGJsonB",
 	"STRUCT":             "interface{}",
 }
 
-var spannerArrTypeMapping = map[string]string{
-	"ARRAY":     "[]",
-	"INT64":     "int64",
-	"STRING":    "string",
-	"BYTES":     "[]byte",
-	"BOOL":      "bool",
-	"FLOAT64":   "float64",
-	"TIMESTAMP": "time.Time",
-	"DATE":      "time.Time",
-	"NUMERIC":   "big.Rat",
-	"JSON":      "spanner.NullJSON",
-}
-
 type Field struct {
 	Name  string
 	Type  string
 	Snake string
+	// Codec is set for custom Go types converted by a generated shim
+	// (CodecType) or by the type itself.
+	Codec     string
+	CodecType string
+	NullWire  string // Spanner value written for nil pointers
+	Pointer   bool
+	Elem      string // Type without the pointer
+	// RoundTrips are the values of the generated round-trip test.
+	RoundTrips []RoundTrip
+	// Generated columns are only read; columns with a Default may be left
+	// out of inserts.
+	Generated  bool
+	HasDefault bool
+	// ServerKey is set on key columns with a DEFAULT, such as a sequence,
+	// which Spanner assigns on insert.
+	ServerKey bool
+	// Constraints checked by the generated Validate.
+	Constrained  bool
+	Required     bool // NOT NULL, and the Go type can be nil
+	MaxLength    int
+	VectorLength int // dimensions of an embedding
+	Enum         bool
+	Checks       []FieldCheck
+}
+
+// StructType is a Go struct generated for a STRUCT type.
+type StructType struct {
+	Name   string
+	Fields []StructField
+}
+
+type StructField struct {
+	Name string
+	Type string
+	Tag  string // the STRUCT field name
+}
+
+// ChangeStreamData is a change stream watching the table, with the
+// non-key columns it records unless AllColumns.
+type ChangeStreamData struct {
+	Name       string
+	Const      string
+	Columns    []string
+	AllColumns bool
 }
 
 type PrimaryKeys struct {
 	Snake       string
 	Camel       string
 	CamelFileld string
+	// Type is the Go type of the key parameter, and Value the expression
+	// of the parameter in a spanner.Key.
+	Type  string
+	Value string
 }
 
 type StructTemplateData struct {
-	StructName  string
-	Fields      []Field
-	PackageName string
-	ModuleName  string
+	StructName    string
+	Fields        []Field
+	PackageName   string
+	ModuleName    string
+	StdImports    []string
+	Imports       []string
+	Structs       []StructType
+	Enums         []EnumType
+	HasCodecs     bool
+	Nullable      string
+	HasRoundTrips bool
+	// Validate makes Create and Update call Validate first.
+	Validate bool
+	// OmitDefaults leaves zero values of DEFAULT columns out of inserts.
+	OmitDefaults bool
+	// View leaves out everything but reads with Get: views have no key and
+	// cannot be written.
+	View bool
+	// Dialect is the dialect of the SQL in Get, "postgresql" or empty.
+	Dialect string
+	// ChangeStreams are the change streams watching the table, whose
+	// records name it ChangeTable.
+	ChangeStreams []ChangeStreamData
+	ChangeTable   string
+	// SearchColumns are the full-text columns Search matches, GoogleSQL
+	// tables only.
+	SearchColumns []SearchColumn
+	// VectorColumns are the embeddings NearestNeighbors searches.
+	VectorColumns []VectorColumn
+	// ServerKeys makes Create insert with DML to read back the key columns
+	// Spanner assigns.
+	ServerKeys bool
+	// KeyGenerator is "uuid4" or "uuid7" to fill in empty keys in the
+	// KeyField on insert.
+	KeyGenerator string
+	KeyField     string
+	// ExpiryField is the field of the row deletion policy, whose rows
+	// expire RetentionDays after it.
+	ExpiryField   string
+	RetentionDays int
+	// Relations are the foreign keys of the table, with loaders of the
+	// rows they reference. Children names the table in their listers, and
+	// Referenced is set if other tables' loaders read this one.
+	Relations   []RelationData
+	Children    string
+	Referenced  bool
 	TableName   string
 	ProjectName string
 	PrimaryKeys []PrimaryKeys
@@ -71,148 +153,420 @@ type StructTemplateData struct {
 }
 
 func main() {
-	moduleName, err := getModuleName()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		}
+	}
+	runGenerate(os.Args[1:])
+}
+
+func runGenerate(args []string) {
+	flags := flag.NewFlagSet("model-gen", flag.ExitOnError)
+	schemaPath := flags.String("schema", "", "generate from a JSON schema (see `model-gen schema`) instead of .sql files; - reads stdin")
+	workers := flags.Int("j", runtime.GOMAXPROCS(0), "number of files parsed and rendered concurrently")
+	force := flags.Bool("force", false, "regenerate every model, ignoring "+cacheFile)
+	diagFormat := diagnosticsFlag(flags)
+	cfgFlags := newConfigFlags(flags)
+	flags.Parse(args)
+
+	diags := &Diagnostics{}
+	defer finish(diags, os.Stdout, *diagFormat)
+
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		diags.report(cfgFlags.path, nil, codeConfig, err)
+		return
+	}
+
+	if *schemaPath == "" {
+		generateFiles(cfg, schemaFiles(cfg, flags.Args(), diags), *workers, *force, false, diags)
+		return
+	}
+
+	schema, err := readSchemaJSON(*schemaPath)
 	if err != nil {
-		log.Fatalln("Error getting module name:", err)
+		diags.report(*schemaPath, nil, codeIO, err)
+		return
+	}
+	schema.setDialect(cfg.Dialect)
+	g, err := newGenerator(cfg, *workers, *force)
+	if err != nil {
+		diags.report(templatePath(cfg), nil, codeTemplate, err)
+		return
+	}
+	g.write(g.render(schema.models(), diags), diags)
+}
+
+func runSchema(args []string) {
+	flags := flag.NewFlagSet("model-gen schema", flag.ExitOnError)
+	formatName := flags.String("format", "json", "output format (json)")
+	out := flags.String("o", "", "write the schema to this file instead of stdout")
+	diagFormat := diagnosticsFlag(flags)
+	cfgFlags := newConfigFlags(flags)
+	flags.Parse(args)
+
+	if *formatName != "json" {
+		log.Fatalf("Unsupported schema format %q\n", *formatName)
 	}
-	for _, path := range findFilePaths() {
-		file, err := os.Open(path)
+
+	diags := &Diagnostics{}
+	// The schema itself goes to stdout unless -o is given.
+	diagOut := os.Stderr
+	if *out != "" {
+		diagOut = os.Stdout
+	}
+	defer finish(diags, diagOut, *diagFormat)
+
+	cfg, err := cfgFlags.load()
+	if err != nil {
+		diags.report(cfgFlags.path, nil, codeConfig, err)
+		return
+	}
+
+	schema := readSchema(cfg, schemaFiles(cfg, flags.Args(), diags), runtime.GOMAXPROCS(0), diags)
+	schema.setDialect(cfg.Dialect)
+
+	w := os.Stdout
+	if *out != "" {
+		w, err = os.Create(*out)
 		if err != nil {
-			log.Fatalln("Error opening file:", err)
+			diags.report(*out, nil, codeIO, err)
+			return
 		}
-		defer file.Close()
+		defer w.Close()
+	}
+	if err := writeSchemaJSON(w, schema); err != nil {
+		diags.report(*out, nil, codeIO, err)
+	}
+}
 
-		// Regex patterns
-		createTableRegex := regexp.MustCompile(`(?i)^CREATE TABLE (\w+)`)
-		columnRegex := regexp.MustCompile(`(?i)^\s*(\w+)\s+(\w+)(?:\((\d+|MAX)\))?(?:\s+NOT\s+NULL)?`)
+// finish prints the collected diagnostics, human readable ones to stderr
+// and JSON ones to jsonOut, and exits non-zero if any of them is an error.
+func finish(diags *Diagnostics, jsonOut io.Writer, format string) {
+	w := io.Writer(os.Stderr)
+	if format == "json" {
+		w = jsonOut
+	}
+	diags.print(w, format)
+	if diags.hasErrors() {
+		os.Exit(1)
+	}
+}
 
-		primartKeysRegex := regexp.MustCompile(`PRIMARY KEY\s*\(([^)]+)\)`)
+// packageName is the name of the directory holding the table's schema,
+// or its Dir, prefixed with the table's named schema if it has one.
+func packageName(table *Table) string {
+	name := strings.ToLower(filepath.Base(table.dir()))
+	if table.Schema != "" {
+		name = strings.ToLower(table.Schema) + "_" + name
+	}
+	return name
+}
 
-		var structName string
-		var fields []Field
-		var primaryKeys []PrimaryKeys
-		var id string
-		// Read file line by line
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
+func outputPath(table *Table) string {
+	return fmt.Sprintf("%s/%s.go", table.dir(), packageName(table))
+}
 
-			// Match table name
-			if matches := createTableRegex.FindStringSubmatch(line); matches != nil {
-				structName = toSnakeCase(matches[1])
-				continue
-			}
+func testOutputPath(table *Table) string {
+	return fmt.Sprintf("%s/%s_test.go", table.dir(), packageName(table))
+}
 
-			// Match column definitions
-			if matches := columnRegex.FindStringSubmatch(line); matches != nil {
-				if strings.Contains(matches[1], "allow_commit_timestamp") {
-					continue
-				}
-				if strings.Contains(matches[1], "INTERLEAVE") {
-					continue
-				}
-
-				var goType string
-				columnName := matches[1]
-				sqlType := matches[2]
-				if strings.Contains(matches[0], "NOT NULL") {
-					sqlType += " NOT NULL"
-				}
-
-				log.Printf("Found -> Column: %s, Type: %s\n", columnName, sqlType)
-
-				if strings.Contains(sqlType, "ARRAY") {
-					goType = parceArray(sqlType)
-				} else {
-					t, ok := spannerTypeMapping[strings.ToUpper(sqlType)]
-					if !ok {
-						goType = "interface{}" // Default to interface{} if unknown type
-					} else {
-						goType = t
-					}
-				}
-
-				fields = append(fields, Field{
-					Name:  toCamelCase(columnName),
-					Type:  goType,
-					Snake: columnName,
-				})
-			}
+func newStructTemplateData(table *Table, types *typeRegistry, diags *Diagnostics) (StructTemplateData, error) {
+	dir := table.dir()
+	module, err := moduleFor(dir)
+	if err != nil {
+		return StructTemplateData{}, fmt.Errorf("resolving module: %w", err)
+	}
 
-			primaryKeysRegex := primartKeysRegex.FindStringSubmatch(line)
-			if len(primaryKeysRegex) > 0 {
-				pk := strings.Split(primaryKeysRegex[1], ",")
-				primaryKeys = make([]PrimaryKeys, len(pk))
-				id = strings.TrimSpace(pk[len(pk)-1])
-				for i, key := range pk {
-					key = strings.TrimSpace(key)
-					primaryKeys[i].Snake = key
-					key = toCamelCase(key)
-					primaryKeys[i].CamelFileld = key
-					key = firstLetterToLower(key)
-					primaryKeys[i].Camel = key
-				}
-			}
+	var fields []Field
+	var typeErrs []error
+	decls := &typeDecls{imports: map[string]bool{}}
+	imports := decls.imports
+	hasCodecs, hasRoundTrips, serverKeys := false, false, false
+	names := newDeclaredNames(table)
+	for _, column := range table.Columns {
+		if !modelColumn(column) {
+			continue
+		}
+		if table.Dialect == dialectPostgreSQL {
+			column = pgColumn(column)
+		}
+		enums, structs := len(decls.enums), len(decls.structs)
+		mapping, diag := types.goType(table, column, decls)
+		if diag != nil && diag.Severity == SeverityError {
+			typeErrs = append(typeErrs, diag)
+		} else if diag != nil {
+			diags.add(diag)
+		}
+		if spec := mapping.importSpec(); spec != "" {
+			imports[spec] = true
 		}
 
-		if err := scanner.Err(); err != nil {
-			log.Fatalln("Error reading file:", err)
+		field := Field{
+			Name:    toCamelCase(column.Name),
+			Type:    mapping.Type,
+			Snake:   columnName(table, column.Name),
+			Codec:   mapping.Codec,
+			Pointer: strings.HasPrefix(mapping.Type, "*"),
+			Elem:    strings.TrimPrefix(mapping.Type, "*"),
+		}
+		switch mapping.Codec {
+		case codecText:
+			field.CodecType = firstLetterToLower(field.Name) + "Codec"
+			field.NullWire = "spanner.NullString"
+		case codecJSON:
+			field.CodecType = firstLetterToLower(field.Name) + "Codec"
+			field.NullWire = "spanner.NullJSON"
+			imports[`"encoding/json"`] = true
+		case codecEnum:
+			field.CodecType = firstLetterToLower(field.Name) + "Codec"
+			field.NullWire = "spanner.NullString"
+			if decls.enums[len(decls.enums)-1].Base == "int64" {
+				field.NullWire = "spanner.NullInt64"
+			}
+		case codecProto:
+			field.CodecType = firstLetterToLower(field.Name) + "Codec"
+			imports[`"encoding/base64"`] = true
+			imports[`"google.golang.org/protobuf/proto"`] = true
+		case codecProtoEnum:
+			field.CodecType = firstLetterToLower(field.Name) + "Codec"
+			imports[`"strconv"`] = true
+		case codecNullArray:
+			field.CodecType = "nullArray[" + strings.TrimSuffix(strings.TrimPrefix(field.Type, "[]Null["), "]") + "]"
 		}
+		hasCodecs = hasCodecs || field.CodecType != ""
 
-		packageName := strings.ToLower(filepath.Base(filepath.Dir(path)))
-		// Generate Go struct
-		data := StructTemplateData{
-			Fields:      fields,
-			PackageName: packageName,
-			ModuleName:  moduleName,
-			TableName:   structName,
-			ProjectName: filepath.Dir(moduleName),
-			PrimaryKeys: primaryKeys,
-			ID:          id,
+		// Custom codecs are up to their types; only the mapping to types
+		// the Spanner client handles itself, and enums, are tested.
+		switch {
+		case len(decls.enums) > enums:
+			field.RoundTrips = enumRoundTrips(column)
+		case column.VectorLength > 0:
+			// Vectors have no NULL elements, unlike the array samples.
+		case mapping.Codec == "" || mapping.Codec == codecNullArray:
+			if t, err := parseSQLType(column.Type); err == nil && mapping.Type != "interface{}" {
+				field.RoundTrips = roundTrips(t, column.NotNull)
+			}
+		}
+		hasRoundTrips = hasRoundTrips || len(field.RoundTrips) > 0
+		field.Generated = column.Generated != ""
+		field.HasDefault = column.Default != ""
+		field.ServerKey = field.HasDefault && !table.view && isKey(table, column)
+		serverKeys = serverKeys || field.ServerKey
+		field.setConstraints(column, len(decls.enums) > enums)
+		fields = append(fields, field)
+		typeErrs = append(typeErrs, names.declare(table, column, field, decls.structs[structs:], decls.enums[enums:])...)
+	}
+	if len(typeErrs) > 0 {
+		return StructTemplateData{}, errors.Join(typeErrs...)
+	}
+	for _, enum := range decls.enums {
+		if enum.Base == "int64" {
+			imports[`"strconv"`] = true
 		}
+	}
+	if types.nullable == nullableGeneric {
+		// Used by the generated Null[T].
+		imports[`"encoding"`] = true
+		imports[`"strconv"`] = true
+	}
 
-		t, err := template.New("structTemplate").Parse(templateString)
-		if err != nil {
-			log.Fatalln("Error parsing template:", err)
+	var expiryField string
+	var retentionDays int
+	if policy := table.DeletionPolicy; policy != nil {
+		if column := table.column(policy.Column); column != nil && modelColumn(column) {
+			expiryField, retentionDays = toCamelCase(column.Name), policy.Days
+			imports[`"time"`] = true
+		} else {
+			diags.warnf(table.Source, table.Pos, codeUnknownRef, "table %s: row deletion policy on unknown column %s", table.Name, policy.Column)
 		}
-		var output bytes.Buffer
-		err = t.Execute(&output, data)
-		if err != nil {
-			log.Fatalln("Error executing template:", err)
+	}
+
+	relations, err := relationsData(table, imports)
+	if err != nil {
+		return StructTemplateData{}, fmt.Errorf("resolving foreign keys: %w", err)
+	}
+
+	// Standard library imports go into the first import group.
+	var stdImports, otherImports []string
+	for _, spec := range sortedKeys(imports) {
+		path := spec[strings.Index(spec, `"`)+1:]
+		if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+			otherImports = append(otherImports, spec)
+		} else {
+			stdImports = append(stdImports, spec)
 		}
+	}
 
-		formattedOutput, err := format.Source(output.Bytes())
-		if err != nil {
-			log.Fatalln("Error formatting output:", err)
+	var id string
+	primaryKeys := make([]PrimaryKeys, len(table.PrimaryKey))
+	for i, key := range table.PrimaryKey {
+		primaryKeys[i].Snake = columnName(table, key.Column)
+		primaryKeys[i].CamelFileld = toCamelCase(key.Column)
+		primaryKeys[i].Camel = firstLetterToLower(primaryKeys[i].CamelFileld)
+		primaryKeys[i].Type, primaryKeys[i].Value = "string", primaryKeys[i].Camel
+		for _, field := range fields {
+			if field.Snake != primaryKeys[i].Snake {
+				continue
+			}
+			switch {
+			case field.CodecType != "":
+				primaryKeys[i].Type = field.Type
+				primaryKeys[i].Value = fmt.Sprintf("encodeField(%s, %s)", field.Name, primaryKeys[i].Camel)
+			case field.Pointer:
+				// spanner.Key takes no pointers.
+				primaryKeys[i].Type = field.Elem
+			default:
+				primaryKeys[i].Type = field.Type
+			}
 		}
+		id = primaryKeys[i].Snake
+	}
 
-		filePath := fmt.Sprintf("%s/%s.go", filepath.Dir(path), packageName)
-		err = os.WriteFile(filePath, formattedOutput, 0644)
-		if err != nil {
-			log.Fatalln("Error writing file:", err)
+	var streams []ChangeStreamData
+	for _, stream := range table.changeStreams {
+		streams = append(streams, ChangeStreamData{
+			Name:       stream.name,
+			Const:      "ChangeStream" + toCamelCase(toSnakeCase(stream.name)),
+			Columns:    stream.columns,
+			AllColumns: stream.columns == nil,
+		})
+	}
+
+	var search []SearchColumn
+	var vectors []VectorColumn
+	if table.Dialect != dialectPostgreSQL && !table.view {
+		search = searchColumns(table)
+		vectors = vectorColumns(table)
+	}
+
+	return StructTemplateData{
+		Fields:        fields,
+		PackageName:   packageName(table),
+		ModuleName:    module.Path,
+		StdImports:    stdImports,
+		Imports:       otherImports,
+		Structs:       decls.structs,
+		Enums:         decls.enums,
+		HasCodecs:     hasCodecs,
+		Nullable:      types.nullable,
+		HasRoundTrips: hasRoundTrips,
+		Dialect:       table.Dialect,
+		View:          table.view,
+		ChangeStreams: streams,
+		ChangeTable:   table.qualifiedName(),
+		SearchColumns: search,
+		VectorColumns: vectors,
+		ServerKeys:    serverKeys,
+		ExpiryField:   expiryField,
+		RetentionDays: retentionDays,
+		Relations:     relations,
+		Children:      toCamelCase(toSnakeCase(table.Name)),
+		Referenced:    table.referenced,
+		TableName:     tableName(table),
+		ProjectName:   path.Dir(module.Path),
+		PrimaryKeys:   primaryKeys,
+		ID:            id,
+	}, nil
+}
+
+// addStdImport adds a standard library import needed by the template
+// rather than by the column types.
+func (d *StructTemplateData) addStdImport(spec string) {
+	for _, s := range d.StdImports {
+		if s == spec {
+			return
 		}
+	}
+	d.StdImports = append(d.StdImports, spec)
+	sort.Strings(d.StdImports)
+}
 
+// declaredNames are the package-level names of a model, and what they
+// were declared for.
+type declaredNames map[string]string
+
+func newDeclaredNames(table *Table) declaredNames {
+	names := declaredNames{}
+	builtin := []string{"Package", "Table", "Facade", "New", "Data", "Field", "QueryParam"}
+	if !table.view {
+		builtin = append(builtin, "ID", "FieldError", "ValidationError", "UpdateFields")
+	}
+	for _, name := range builtin {
+		names[name] = "the " + name + " of the template"
 	}
+	return names
 }
 
-func parceArray(s string) string {
-	s = strings.Replace(s, "<", " ", -1)
-	s = strings.Replace(s, ">", " ", -1)
-	arr := strings.Fields(s)
-	var goType string
-	for _, a := range arr {
-		for i, t := range a {
-			if t == '(' {
-				a = a[:i]
-				break
+// declare adds the names generated for column: its Field constant, and
+// the struct and enum types and enum constants of its type. Names already
+// taken are returned as errors, as the model would not compile.
+func (n declaredNames) declare(table *Table, column *Column, field Field, structs []StructType, enums []EnumType) []error {
+	var errs []error
+	add := func(name, what string) {
+		if other, ok := n[name]; ok {
+			diag := &Diagnostic{
+				File:     table.columnSource(column),
+				Severity: SeverityError,
+				Code:     codeConflict,
+				Message:  fmt.Sprintf("table %s: %s and %s are both named %s", table.Name, what, other, name),
+			}
+			if column.Pos != nil {
+				diag.Line, diag.Column = column.Pos.Line, column.Pos.Column
 			}
+			errs = append(errs, diag)
+			return
 		}
-		t, ok := spannerArrTypeMapping[strings.ToUpper(a)]
-		if !ok {
-			goType = "interface{}" // Default to interface{} if unknown type
-		} else {
-			goType += t
+		n[name] = what
+	}
+
+	add(field.Name, "the field of column "+column.Name)
+	for _, st := range structs {
+		add(st.Name, "the struct type of column "+column.Name)
+	}
+	for _, enum := range enums {
+		add(enum.Name, "the enum type of column "+column.Name)
+		for _, v := range enum.Values {
+			add(v.Name, fmt.Sprintf("the constant of value %s of column %s", v.Literal, column.Name))
+		}
+	}
+	return errs
+}
+
+func isKey(table *Table, column *Column) bool {
+	for _, key := range table.PrimaryKey {
+		if key.Column == column.Name {
+			return true
 		}
 	}
-	return goType
+	return false
+}
+
+// tableName is the name the generated code uses for the table.
+// PostgreSQL folds unquoted names to lower case and keeps quoted ones
+// as declared, and its queries quote the name.
+func tableName(table *Table) string {
+	name, schema := toSnakeCase(table.Name), table.Schema
+	if table.Dialect == dialectPostgreSQL {
+		name, schema = pgName(table.Name, table.Quoted), pgName(table.Schema, table.SchemaQuoted)
+	}
+	if schema != "" {
+		return schema + "." + name
+	}
+	return name
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultModelsDir is where the models of a migrated schema are written
+// unless Config.Models is set.
+const defaultModelsDir = "models"
+
+var (
+	migrationNumberRegex    = regexp.MustCompile(`^\d+`)
+	ifNotExistsRegex        = regexp.MustCompile(`(?is)^CREATE\s+[\w\s]*?\bIF\s+NOT\s+EXISTS\b`)
+	orReplaceRegex          = regexp.MustCompile(`(?is)^CREATE\s+OR\s+REPLACE\b`)
+	alterTableRegex         = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(` + namePattern + `)\s+(.*)$`)
+	dropObjectRegex         = regexp.MustCompile(`(?is)^DROP\s+(TABLE|(?:SEARCH\s+|VECTOR\s+)?INDEX|VIEW|SEQUENCE|CHANGE\s+STREAM)\s+(IF\s+EXISTS\s+)?(` + namePattern + `)\s*$`)
+	renameTableRegex        = regexp.MustCompile(`(?is)^RENAME\s+TO\s+(` + namePattern + `)$`)
+	setOnDeleteRegex        = regexp.MustCompile(`(?is)^SET\s+ON\s+DELETE\s+(CASCADE|NO\s+ACTION)$`)
+	addDeletionPolicyRegex  = regexp.MustCompile(`(?is)^(?:ADD|REPLACE|SET)\s+(?:ROW\s+DELETION\s+POLICY|TTL)\b`)
+	dropDeletionPolicyRegex = regexp.MustCompile(`(?is)^DROP\s+(?:ROW\s+DELETION\s+POLICY|TTL)$`)
+	addConstraintRegex      = regexp.MustCompile(`(?is)^ADD\s+((?:CONSTRAINT\s+` + identPattern + `\s+)?(?:CHECK|FOREIGN\s+KEY|PRIMARY\s+KEY|UNIQUE)\b.*)$`)
+	dropConstraintRegex     = regexp.MustCompile(`(?is)^DROP\s+CONSTRAINT\s+(?:IF\s+EXISTS\s+)?(` + identPattern + `)`)
+	synonymRegex            = regexp.MustCompile(`(?is)^(?:ADD|DROP)\s+SYNONYM\b`)
+	addColumnRegex          = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(IF\s+NOT\s+EXISTS\s+)?`)
+	dropColumnRegex         = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(IF\s+EXISTS\s+)?(` + identPattern + `)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	alterColumnRegex        = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(` + identPattern + `)\s+(.*)$`)
+	setColumnOptionsRegex   = regexp.MustCompile(`(?is)^SET\s+OPTIONS\s*\((.*)\)$`)
+	setDefaultRegex         = regexp.MustCompile(`(?is)^SET\s+DEFAULT\s+(.*)$`)
+	dropDefaultRegex        = regexp.MustCompile(`(?is)^DROP\s+DEFAULT$`)
+	setNotNullRegex         = regexp.MustCompile(`(?is)^(SET|DROP)\s+NOT\s+NULL$`)
+	alterTypeRegex          = regexp.MustCompile(`(?is)^(?:SET\s+DATA\s+)?TYPE\s+(.*)$`)
+)
+
+// migrationFiles returns the .sql files of dir in the order of the number
+// their names start with, such as 0001_users.sql. Files without a number
+// cannot be ordered and are reported and skipped.
+func migrationFiles(dir string, diags *Diagnostics) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		diags.report(dir, nil, codeIO, err)
+		return nil
+	}
+	type migration struct {
+		number int
+		name   string
+	}
+	var migrations []migration
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		number, err := strconv.Atoi(migrationNumberRegex.FindString(e.Name()))
+		if err != nil {
+			diags.warnf(filepath.Join(dir, e.Name()), nil, codeMigration, "migration %s is not numbered, skipping it", e.Name())
+			continue
+		}
+		migrations = append(migrations, migration{number, e.Name()})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		if migrations[i].number != migrations[j].number {
+			return migrations[i].number < migrations[j].number
+		}
+		return migrations[i].name < migrations[j].name
+	})
+	paths := make([]string, len(migrations))
+	for i, m := range migrations {
+		paths[i] = filepath.Join(dir, m.name)
+	}
+	return paths
+}
+
+// readSchema builds the schema by replaying paths as migrations, if
+// configured, or else by parsing them as schema files.
+func readSchema(cfg *Config, paths []string, workers int, diags *Diagnostics) *Schema {
+	if cfg.Migrations != "" {
+		return replayMigrations(paths, cfg.Models, diags)
+	}
+	return parseSchema(paths, workers, diags)
+}
+
+// replayMigrations applies the migrations in paths one statement at a
+// time, in order, and returns the resulting schema. Every table and view
+// is given a package of its own below models.
+func replayMigrations(paths []string, models string, diags *Diagnostics) *Schema {
+	wd, _ := os.Getwd()
+	schema := &Schema{Version: SchemaVersion}
+	for _, path := range paths {
+		source := sourceName(wd, path)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			diags.report(source, nil, codeIO, err)
+			continue
+		}
+		f := newSourceFile(source, string(src))
+		for _, stmt := range f.statements(diags) {
+			schema.migrate(f, stmt, diags)
+		}
+	}
+	schema.resolve(diags)
+
+	if models == "" {
+		models = defaultModelsDir
+	}
+	for _, t := range schema.Tables {
+		t.Dir = modelDir(models, t.Schema, t.Name)
+	}
+	for _, v := range schema.Views {
+		v.Dir = modelDir(models, v.Schema, v.Name)
+	}
+	return schema
+}
+
+// modelDir is the package directory of a migrated table or view:
+// models/[schema/]name, lower case and without underscores.
+func modelDir(models, schema, name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	if schema != "" {
+		return filepath.ToSlash(filepath.Join(models, strings.ToLower(schema), name))
+	}
+	return filepath.ToSlash(filepath.Join(models, name))
+}
+
+// migrate applies one statement of a migration to s. CREATE statements
+// add to the schema as in schema files; ALTER TABLE and DROP change what
+// earlier migrations created.
+func (s *Schema) migrate(f *sourceFile, stmt span, diags *Diagnostics) {
+	if m := alterTableRegex.FindStringSubmatchIndex(stmt.text); m != nil {
+		s.alterTable(f, stmt, m, diags)
+		return
+	}
+	if m := dropObjectRegex.FindStringSubmatch(stmt.text); m != nil {
+		if err := s.drop(strings.ToUpper(strings.Join(strings.Fields(m[1]), " ")), qualifiedName(m[3])); err != nil && m[2] == "" {
+			diags.add(f.errorf(stmt.offset, codeUnknownRef, "%v", err))
+		}
+		return
+	}
+
+	parsed := &parsedFile{}
+	parsed.add(f, stmt, diags)
+	for _, t := range parsed.tables {
+		if s.table(t.qualifiedName()) != nil {
+			if !ifNotExistsRegex.MatchString(stmt.text) {
+				diags.report(f.name, t.Pos, codeMigration, fmt.Errorf("table %s already exists", t.qualifiedName()))
+			}
+			continue
+		}
+		s.Tables = append(s.Tables, t)
+	}
+	for _, v := range parsed.views {
+		if i := s.viewIndex(v); i >= 0 {
+			if !orReplaceRegex.MatchString(stmt.text) {
+				diags.report(f.name, v.Pos, codeMigration, fmt.Errorf("view %s already exists", v.Name))
+				continue
+			}
+			s.Views = append(s.Views[:i], s.Views[i+1:]...)
+		}
+		s.Views = append(s.Views, v)
+	}
+	parsed.tables, parsed.views = nil, nil
+	s.add(parsed)
+	for _, ti := range parsed.indexes {
+		s.addIndex(ti, diags)
+	}
+}
+
+func (s *Schema) viewIndex(view *View) int {
+	for i, v := range s.Views {
+		if v.Name == view.Name && v.Schema == view.Schema {
+			return i
+		}
+	}
+	return -1
+}
+
+// drop removes the table, index, view, sequence or change stream name.
+func (s *Schema) drop(kind, name string) error {
+	switch {
+	case kind == "TABLE":
+		for i, t := range s.Tables {
+			if t.qualifiedName() == name {
+				s.Tables = append(s.Tables[:i], s.Tables[i+1:]...)
+				return nil
+			}
+		}
+	case strings.HasSuffix(kind, "INDEX"):
+		for _, t := range s.Tables {
+			for i, index := range t.Indexes {
+				if index.Name == name {
+					t.Indexes = append(t.Indexes[:i], t.Indexes[i+1:]...)
+					return nil
+				}
+			}
+		}
+	case kind == "VIEW":
+		schema, viewName := splitName(name)
+		if i := s.viewIndex(&View{Name: viewName, Schema: schema}); i >= 0 {
+			s.Views = append(s.Views[:i], s.Views[i+1:]...)
+			return nil
+		}
+	case kind == "SEQUENCE":
+		for i, seq := range s.Sequences {
+			if seq.qualifiedName() == name {
+				s.Sequences = append(s.Sequences[:i], s.Sequences[i+1:]...)
+				return nil
+			}
+		}
+	case kind == "CHANGE STREAM":
+		for i, stream := range s.ChangeStreams {
+			if stream.Name == name {
+				s.ChangeStreams = append(s.ChangeStreams[:i], s.ChangeStreams[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("DROP %s: unknown %s", kind, name)
+}
+
+// alterTable applies the comma separated actions of an ALTER TABLE, up to
+// the first one that fails.
+func (s *Schema) alterTable(f *sourceFile, stmt span, m []int, diags *Diagnostics) {
+	name := qualifiedName(stmt.text[m[2]:m[3]])
+	table := s.table(name)
+	if table == nil {
+		diags.add(f.errorf(stmt.offset+m[2], codeUnknownRef, "ALTER TABLE: unknown table %s", name))
+		return
+	}
+	for _, action := range splitTopLevelSpans(stmt.sub(m[4], m[5]), ',') {
+		if err := s.alterTableAction(f, table, action, diags); err != nil {
+			diags.report(f.name, f.pos(action.offset), codeSyntax, err)
+			return
+		}
+	}
+}
+
+// alterTableAction applies one action of an ALTER TABLE to table.
+// Unsupported actions, such as SET OPTIONS, are reported and skipped.
+func (s *Schema) alterTableAction(f *sourceFile, table *Table, action span, diags *Diagnostics) error {
+	text := action.text
+	switch {
+	case renameTableRegex.MatchString(text):
+		old := table.qualifiedName()
+		name := renameTableRegex.FindStringSubmatch(text)[1]
+		table.Schema, table.Name = splitName(name)
+		table.Quoted, table.SchemaQuoted = nameQuoted(name), schemaQuoted(name)
+		for _, t := range s.Tables {
+			if t.Interleave != nil && t.Interleave.Parent == old {
+				t.Interleave.Parent = table.qualifiedName()
+			}
+		}
+	case setOnDeleteRegex.MatchString(text):
+		if table.Interleave == nil {
+			return f.errorf(action.offset, codeMigration, "ALTER TABLE %s: table is not interleaved", table.Name)
+		}
+		onDelete := setOnDeleteRegex.FindStringSubmatch(text)[1]
+		table.Interleave.OnDelete = strings.ToUpper(strings.Join(strings.Fields(onDelete), " "))
+	case addDeletionPolicyRegex.MatchString(text):
+		policy := parseDeletionPolicy(text)
+		if policy == nil {
+			return f.errorf(action.offset, codeSyntax, "ALTER TABLE %s: cannot parse row deletion policy", table.Name)
+		}
+		if table.column(policy.Column) == nil {
+			return f.errorf(action.offset, codeUnknownRef, "ALTER TABLE %s: unknown column %s", table.Name, policy.Column)
+		}
+		table.DeletionPolicy = policy
+	case dropDeletionPolicyRegex.MatchString(text):
+		table.DeletionPolicy = nil
+	case addConstraintRegex.MatchString(text):
+		m := addConstraintRegex.FindStringSubmatchIndex(text)
+		return s.addConstraint(f, table, action.sub(m[2], m[3]))
+	case dropConstraintRegex.MatchString(text):
+		// Only foreign keys are dropped: CHECK constraints are not named
+		// in the schema, and the values and checks they allow are kept.
+		name := unquoteIdent(dropConstraintRegex.FindStringSubmatch(text)[1])
+		for i, fk := range table.ForeignKeys {
+			if fk.Name == name {
+				table.ForeignKeys = append(table.ForeignKeys[:i], table.ForeignKeys[i+1:]...)
+				break
+			}
+		}
+	case synonymRegex.MatchString(text):
+		// Synonyms do not change the models.
+	case addColumnRegex.MatchString(text):
+		m := addColumnRegex.FindStringSubmatchIndex(text)
+		column, _, err := parseColumn(f, action.sub(m[1], len(text)))
+		if err != nil {
+			return err
+		}
+		if table.column(column.Name) != nil {
+			if m[2] >= 0 {
+				return nil // IF NOT EXISTS
+			}
+			return f.errorf(action.offset, codeMigration, "ALTER TABLE %s: column %s already exists", table.Name, column.Name)
+		}
+		if f.name != table.Source {
+			column.Source = f.name
+		}
+		if fk := column.references; fk != nil {
+			fk.Columns, column.references = []string{column.Name}, nil
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+		}
+		table.Columns = append(table.Columns, column)
+	case dropColumnRegex.MatchString(text):
+		m := dropColumnRegex.FindStringSubmatch(text)
+		return dropColumn(f, table, action, unquoteIdent(m[2]), m[1] != "")
+	case alterColumnRegex.MatchString(text):
+		return alterColumn(f, table, action)
+	default:
+		diags.warnf(f.name, f.pos(action.offset), codeSyntax, "ALTER TABLE %s: unsupported action %q, skipping it", table.Name, text)
+	}
+	return nil
+}
+
+// addConstraint applies an added CHECK or FOREIGN KEY constraint the way
+// CREATE TABLE does. Other constraints do not change the models.
+func (s *Schema) addConstraint(f *sourceFile, table *Table, def span) error {
+	constraint := def.text
+	column := func(name string) (*Column, error) {
+		if c := table.column(name); c != nil {
+			return c, nil
+		}
+		return nil, f.errorf(def.offset, codeUnknownRef, "ALTER TABLE %s: unknown column %s", table.Name, name)
+	}
+	if fk := parseForeignKey(f, def); fk != nil {
+		for _, name := range fk.Columns {
+			if _, err := column(name); err != nil {
+				return err
+			}
+		}
+		if s.table(fk.RefTable) == nil {
+			return f.errorf(def.offset, codeUnknownRef, "ALTER TABLE %s: foreign key references unknown table %s", table.Name, fk.RefTable)
+		}
+		table.ForeignKeys = append(table.ForeignKeys, fk)
+	} else if m := checkRegex.FindStringSubmatch(constraint); m != nil {
+		name, values, comparisons := parseCheck(m[1])
+		if values != nil {
+			c, err := column(name)
+			if err != nil {
+				return err
+			}
+			c.Values = values
+		}
+		for name, checks := range comparisons {
+			c, err := column(name)
+			if err != nil {
+				return err
+			}
+			c.Checks = append(c.Checks, checks...)
+		}
+	}
+	return nil
+}
+
+// dropColumn removes a column, which Spanner refuses while it is part of
+// the primary key, a foreign key or an index.
+func dropColumn(f *sourceFile, table *Table, action span, name string, ifExists bool) error {
+	if table.column(name) == nil {
+		if ifExists {
+			return nil
+		}
+		return f.errorf(action.offset, codeUnknownRef, "ALTER TABLE %s: unknown column %s", table.Name, name)
+	}
+	for _, k := range table.PrimaryKey {
+		if k.Column == name {
+			return f.errorf(action.offset, codeMigration, "ALTER TABLE %s: cannot drop key column %s", table.Name, name)
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		for _, c := range fk.Columns {
+			if c == name {
+				return f.errorf(action.offset, codeMigration, "ALTER TABLE %s: cannot drop column %s used by a foreign key", table.Name, name)
+			}
+		}
+	}
+	for _, index := range table.Indexes {
+		used := false
+		for _, k := range index.Columns {
+			used = used || k.Column == name
+		}
+		for _, c := range index.Storing {
+			used = used || c == name
+		}
+		if used {
+			return f.errorf(action.offset, codeMigration, "ALTER TABLE %s: cannot drop column %s used by index %s", table.Name, name, index.Name)
+		}
+	}
+	for i, c := range table.Columns {
+		if c.Name == name {
+			table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// alterColumn applies ALTER COLUMN: SET OPTIONS, SET or DROP DEFAULT,
+// SET or DROP NOT NULL, PostgreSQL's TYPE, or a new definition of the
+// column, which keeps its constraints.
+func alterColumn(f *sourceFile, table *Table, action span) error {
+	m := alterColumnRegex.FindStringSubmatchIndex(action.text)
+	name := unquoteIdent(action.text[m[2]:m[3]])
+	column := table.column(name)
+	if column == nil {
+		return f.errorf(action.offset, codeUnknownRef, "ALTER TABLE %s: unknown column %s", table.Name, name)
+	}
+	change := action.text[m[4]:m[5]]
+	switch {
+	case setColumnOptionsRegex.MatchString(change):
+		if column.Options == nil {
+			column.Options = map[string]string{}
+		}
+		for k, v := range parseOptions(setColumnOptionsRegex.FindStringSubmatch(change)[1]) {
+			if strings.EqualFold(v, "null") {
+				delete(column.Options, k)
+			} else {
+				column.Options[k] = v
+			}
+		}
+	case setDefaultRegex.MatchString(change):
+		column.Default = unwrapParens(setDefaultRegex.FindStringSubmatch(change)[1])
+		column.Sequence = defaultSequence(column.Default)
+	case dropDefaultRegex.MatchString(change):
+		column.Default, column.Sequence = "", ""
+	case setNotNullRegex.MatchString(change):
+		column.NotNull = strings.EqualFold(setNotNullRegex.FindStringSubmatch(change)[1], "SET")
+	case alterTypeRegex.MatchString(change):
+		def := span{text: action.text[m[2]:m[3]] + " " + alterTypeRegex.FindStringSubmatch(change)[1], offset: action.offset}
+		typed, _, err := parseColumn(f, def)
+		if err != nil {
+			return err
+		}
+		column.Type, column.Length, column.VectorLength = typed.Type, typed.Length, typed.VectorLength
+	default:
+		redefined, _, err := parseColumn(f, action.sub(m[2], len(action.text)))
+		if err != nil {
+			return err
+		}
+		redefined.Values, redefined.Checks = column.Values, column.Checks
+		if f.name != table.Source {
+			redefined.Source = f.name
+		}
+		*column = *redefined
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// replaySQL replays the migrations among files, written into
+// db/migrations of a new directory.
+func replaySQL(t *testing.T, files map[string]string) (*Schema, *Diagnostics) {
+	t.Helper()
+	dir := filepath.Join(writeFiles(t, files), "db", "migrations")
+	diags := &Diagnostics{}
+	return replayMigrations(migrationFiles(dir, diags), "", diags), diags
+}
+
+// columnNames returns the names of the columns of table.
+func columnNames(table *Table) []string {
+	var names []string
+	for _, c := range table.Columns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestMigrationFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"10_orders.sql":  "",
+		"3_users.sql":    "",
+		"0001_init.sql":  "",
+		"3_accounts.sql": "",
+		"seed.sql":       "",
+		"README.md":      "",
+	})
+	diags := &Diagnostics{}
+	var got []string
+	for _, path := range migrationFiles(dir, diags) {
+		got = append(got, filepath.Base(path))
+	}
+	if want := []string{"0001_init.sql", "3_accounts.sql", "3_users.sql", "10_orders.sql"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("migrations = %q, want %q", got, want)
+	}
+	if len(diags.list) != 1 || diags.hasErrors() || !hasDiagnostic(diags, codeMigration, "migration seed.sql is not numbered") {
+		t.Errorf("diagnostics:\n%s", diagnosticsText(diags))
+	}
+}
+
+func TestReplayMigrations(t *testing.T) {
+	schema, diags := replaySQL(t, map[string]string{
+		// 10 sorts before 2 by name: replaying it first would alter
+		// tables that do not exist yet.
+		"db/migrations/2_init.sql": `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+) PRIMARY KEY (id);
+
+CREATE TABLE orders (
+  id STRING(36) NOT NULL,
+  total INT64,
+) PRIMARY KEY (id);
+`,
+		"db/migrations/10_change.sql": `
+ALTER TABLE users ADD COLUMN email STRING(MAX) NOT NULL;
+ALTER TABLE users DROP COLUMN name;
+ALTER TABLE users ALTER COLUMN email SET DEFAULT ('');
+DROP TABLE orders;
+CREATE TABLE orders (
+  id STRING(36) NOT NULL,
+  user_id STRING(36) NOT NULL,
+  amount NUMERIC,
+) PRIMARY KEY (id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email STRING(MAX);
+DROP INDEX IF EXISTS orders_by_user;
+`,
+	})
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	users, orders := schema.table("users"), schema.table("orders")
+	if users == nil || orders == nil || len(schema.Tables) != 2 {
+		t.Fatalf("tables = %+v", schema.Tables)
+	}
+	if got := columnNames(users); !reflect.DeepEqual(got, []string{"id", "email"}) {
+		t.Errorf("users columns = %q", got)
+	}
+	if email := users.column("email"); !email.NotNull || email.Default != "''" {
+		t.Errorf("email = %+v", email)
+	}
+	if got := columnNames(orders); !reflect.DeepEqual(got, []string{"id", "user_id", "amount"}) {
+		t.Errorf("orders columns = %q", got)
+	}
+	if users.Dir != "models/users" || orders.Dir != "models/orders" {
+		t.Errorf("dirs = %s, %s", users.Dir, orders.Dir)
+	}
+}
+
+func TestReplayMigrationErrors(t *testing.T) {
+	const init = `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+) PRIMARY KEY (id);
+`
+	tests := []struct {
+		name    string
+		sql     string
+		code    string
+		message string
+		line    int
+	}{
+		{
+			name:    "alter unknown table",
+			sql:     "\nALTER TABLE accounts ADD COLUMN note STRING(MAX);",
+			code:    codeUnknownRef,
+			message: "ALTER TABLE: unknown table accounts",
+			line:    2,
+		},
+		{
+			name:    "create existing table",
+			sql:     "CREATE TABLE users (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+			code:    codeMigration,
+			message: "table users already exists",
+			line:    1,
+		},
+		{
+			name:    "drop unknown table",
+			sql:     "DROP TABLE accounts;",
+			code:    codeUnknownRef,
+			message: "DROP TABLE: unknown accounts",
+			line:    1,
+		},
+		{
+			name:    "drop dropped table",
+			sql:     "DROP TABLE users;\nDROP TABLE users;",
+			code:    codeUnknownRef,
+			message: "DROP TABLE: unknown users",
+			line:    2,
+		},
+		{
+			name:    "alter dropped table",
+			sql:     "DROP TABLE users;\nALTER TABLE users ADD COLUMN note STRING(MAX);",
+			code:    codeUnknownRef,
+			message: "ALTER TABLE: unknown table users",
+			line:    2,
+		},
+		{
+			name:    "add existing column",
+			sql:     "ALTER TABLE users ADD COLUMN name STRING(MAX);",
+			code:    codeMigration,
+			message: "ALTER TABLE users: column name already exists",
+			line:    1,
+		},
+		{
+			name:    "drop unknown column",
+			sql:     "ALTER TABLE users DROP COLUMN note;",
+			code:    codeUnknownRef,
+			message: "ALTER TABLE users: unknown column note",
+			line:    1,
+		},
+		{
+			name:    "drop key column",
+			sql:     "ALTER TABLE users DROP COLUMN id;",
+			code:    codeMigration,
+			message: "ALTER TABLE users: cannot drop key column id",
+			line:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, diags := replaySQL(t, map[string]string{
+				"db/migrations/0001_init.sql":   init,
+				"db/migrations/0002_change.sql": tt.sql,
+			})
+			if len(diags.list) != 1 {
+				t.Fatalf("diagnostics:\n%s", diagnosticsText(diags))
+			}
+			d := diags.list[0]
+			if d.Code != tt.code || d.Message != tt.message || d.Line != tt.line || filepath.Base(d.File) != "0002_change.sql" {
+				t.Errorf("diagnostic = %s:%d %s %s, want 0002_change.sql:%d %s %s", d.File, d.Line, d.Code, d.Message, tt.line, tt.code, tt.message)
+			}
+			// The failed statement leaves users as it was, if it still exists.
+			if users := schema.table("users"); users != nil && !reflect.DeepEqual(columnNames(users), []string{"id", "name"}) {
+				t.Errorf("users columns = %q", columnNames(users))
+			}
+		})
+	}
+}
+
+func TestReplayDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want *DeletionPolicy
+	}{
+		{"ALTER TABLE events ADD ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 DAY));", &DeletionPolicy{Column: "created", Days: 30}},
+		{"ALTER TABLE events ADD ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 DAY));\n" +
+			"ALTER TABLE events REPLACE ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 7 DAY));", &DeletionPolicy{Column: "created", Days: 7}},
+		{"ALTER TABLE events ADD ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 DAY));\n" +
+			"ALTER TABLE events DROP ROW DELETION POLICY;", nil},
+	}
+	for _, tt := range tests {
+		schema, diags := replaySQL(t, map[string]string{
+			"db/migrations/0001_init.sql":   "CREATE TABLE events (id STRING(36) NOT NULL, created TIMESTAMP) PRIMARY KEY (id);",
+			"db/migrations/0002_change.sql": tt.sql,
+		})
+		if len(diags.list) != 0 {
+			t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+		}
+		if got := schema.table("events").DeletionPolicy; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: policy = %+v, want %+v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -4,12 +4,68 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 )
 
-func getModuleName() (string, error) {
+type goModule struct {
+	Path string // module path from the module directive
+	Dir  string // absolute directory holding go.mod
+}
+
+// importPath returns the import path of the package in dir, which must be
+// inside the module.
+func (m *goModule) importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(m.Dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of module %s", dir, m.Path)
+	}
+	return path.Join(m.Path, filepath.ToSlash(rel)), nil
+}
+
+var (
+	modulesMu sync.Mutex
+	modules   = map[string]*goModule{}
+)
+
+// moduleFor walks up from dir to the nearest go.mod and returns the module
+// it declares. Results are cached per go.mod directory.
+func moduleFor(dir string) (*goModule, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	for d := abs; ; d = filepath.Dir(d) {
+		if m, ok := modules[d]; ok {
+			return m, nil
+		}
+		name, err := getModuleName(filepath.Join(d, "go.mod"))
+		if err == nil {
+			m := &goModule{Path: name, Dir: d}
+			modules[d] = m
+			return m, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if filepath.Dir(d) == d {
+			return nil, fmt.Errorf("no go.mod found for %s", dir)
+		}
+	}
+}
+
+func getModuleName(goMod string) (string, error) {
 	// Open the go.mod file
-	file, err := os.Open("go.mod")
+	file, err := os.Open(goMod)
 	if err != nil {
 		return "", err
 	}
@@ -18,11 +74,11 @@ func getModuleName() (string, error) {
 	// Read the file line by line
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := stripLineComment(scanner.Text())
 		// Look for the line starting with "module"
 		if strings.HasPrefix(line, "module ") {
 			// Extract and return the module name
-			return strings.TrimSpace(strings.TrimPrefix(line, "module ")), nil
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
 		}
 	}
 
@@ -31,5 +87,66 @@ func getModuleName() (string, error) {
 		return "", err
 	}
 
-	return "", fmt.Errorf("module name not found")
+	return "", fmt.Errorf("module name not found in %s", goMod)
+}
+
+// workspaceDirs returns the directory of the go.work file governing the
+// current directory, honouring GOWORK, and the module directories it uses.
+// It returns an empty root outside of a workspace.
+func workspaceDirs() (string, []string, error) {
+	goWork := os.Getenv("GOWORK")
+	if goWork == "off" {
+		return "", nil, nil
+	}
+	if goWork == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", nil, err
+		}
+		for d := wd; ; d = filepath.Dir(d) {
+			if _, err := os.Stat(filepath.Join(d, "go.work")); err == nil {
+				goWork = filepath.Join(d, "go.work")
+				break
+			}
+			if filepath.Dir(d) == d {
+				return "", nil, nil
+			}
+		}
+	}
+
+	file, err := os.Open(goWork)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	var dirs []string
+	inUse := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := stripLineComment(scanner.Text())
+		switch {
+		case line == "use (":
+			inUse = true
+			continue
+		case inUse && line == ")":
+			inUse = false
+			continue
+		case strings.HasPrefix(line, "use "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "use "))
+		case !inUse:
+			continue
+		}
+		if line = strings.Trim(line, `"`); line != "" {
+			dirs = append(dirs, filepath.Join(filepath.Dir(goWork), line))
+		}
+	}
+	return filepath.Dir(goWork), dirs, scanner.Err()
+}
+
+func stripLineComment(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
 }
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// identPattern matches an identifier, bare or quoted with backticks
+// (GoogleSQL) or double quotes (PostgreSQL); namePattern one that may be
+// qualified by a schema.
+const (
+	identPattern = "(?:[A-Za-z_]\\w*|`[^`]+`|\"[^\"]+\")"
+	namePattern  = identPattern + `(?:\s*\.\s*` + identPattern + `)?`
+)
+
+var (
+	createTableRegex    = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + namePattern + `)\s*\(`)
+	createIndexRegex    = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?(NULL_FILTERED\s+)?(SEARCH\s+|VECTOR\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + namePattern + `)\s+ON\s+(` + namePattern + `)\s*\(([^)]*)\)(.*)$`)
+	createSchemaRegex   = regexp.MustCompile(`(?is)^CREATE\s+SCHEMA\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + identPattern + `)\s*$`)
+	columnNameRegex     = regexp.MustCompile(`^(` + identPattern + `)\s+`)
+	typeLengthRegex     = regexp.MustCompile(`(?i)^(\w+)\s*\(\s*(\d+|MAX)\s*\)$`)
+	constraintRegex     = regexp.MustCompile(`(?i)^(CONSTRAINT|FOREIGN\s+KEY|CHECK|PRIMARY\s+KEY)\b`)
+	checkRegex          = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+` + identPattern + `\s+)?CHECK\s*\((.*)\)$`)
+	columnCheckRegex    = regexp.MustCompile(`(?i)(?:\bCONSTRAINT\s+` + identPattern + `\s+)?\bCHECK\s*\(`)
+	inListRegex         = regexp.MustCompile(`(?is)^(` + identPattern + `)\s+IN\s*\((.*)\)$`)
+	comparisonRegex     = regexp.MustCompile("(?s)^(\\w+|`[^`]+`|" + `'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|-?[\d.]+(?:[eE][-+]?\d+)?)\s*(<=|>=|<>|!=|=|<|>)\s*(\w+|` + "`[^`]+`|" + `'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|-?[\d.]+(?:[eE][-+]?\d+)?)$`)
+	andRegex            = regexp.MustCompile(`(?i)\s+AND\s+`)
+	intLiteralRegex     = regexp.MustCompile(`^-?\d+$`)
+	numLiteralRegex     = regexp.MustCompile(`^-?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?$`)
+	notNullRegex        = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	optionsRegex        = regexp.MustCompile(`(?i)\bOPTIONS\s*\(`)
+	defaultRegex        = regexp.MustCompile(`(?i)\bDEFAULT\s*\(`)
+	bareDefaultRegex    = regexp.MustCompile(`(?i)\bDEFAULT\s+([\w.]+\s*\(|'(?:[^'\\]|\\.)*'|[-\w.]+(?:::\w+)?)`)
+	columnPKRegex       = regexp.MustCompile(`(?i)\bPRIMARY\s+KEY\b`)
+	generatedRegex      = regexp.MustCompile(`(?i)\bAS\s*\(`)
+	storedRegex         = regexp.MustCompile(`(?i)^\s*STORED\b`)
+	hiddenRegex         = regexp.MustCompile(`(?i)\bHIDDEN\b`)
+	primaryKeysRegex    = regexp.MustCompile(`(?i)PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	interleaveRegex     = regexp.MustCompile(`(?i)INTERLEAVE\s+IN\s+PARENT\s+(` + namePattern + `)(?:\s+ON\s+DELETE\s+(CASCADE|NO\s+ACTION))?`)
+	deletionPolicyRegex = regexp.MustCompile(`(?is)\bROW\s+DELETION\s+POLICY\s*\(\s*OLDER_THAN\s*\(\s*(` + identPattern + `)\s*,\s*INTERVAL\s+(\d+)\s+DAY\s*\)\s*\)`)
+	pgTTLRegex          = regexp.MustCompile(`(?is)\bTTL\s+INTERVAL\s+'\s*(\d+)\s+days?\s*'\s+ON\s+(` + identPattern + `)`)
+	storingRegex        = regexp.MustCompile(`(?i)STORING\s*\(([^)]*)\)`)
+	indexParentRegex    = regexp.MustCompile(`(?i)INTERLEAVE\s+IN\s+(` + namePattern + `)`)
+)
+
+// parsedFile holds the statements of one .sql file.
+type parsedFile struct {
+	schemas   []string
+	tables    []*Table
+	views     []*View
+	streams   []*ChangeStream
+	sequences []*Sequence
+	indexes   []tableIndex
+}
+
+type tableIndex struct {
+	table  string
+	source string
+	index  *Index
+}
+
+// parseSchema reads every .sql file in paths, on up to workers goroutines,
+// and builds the schema IR. Source paths are stored relative to the working
+// directory so the IR stays stable across machines. Statements that fail
+// to parse are reported to diags and left out; the rest of the schema is
+// still returned.
+func parseSchema(paths []string, workers int, diags *Diagnostics) *Schema {
+	wd, _ := os.Getwd()
+
+	files := make([]*parsedFile, len(paths))
+	forEach(len(paths), workers, func(i int) {
+		files[i] = parseFile(paths[i], sourceName(wd, paths[i]), diags)
+	})
+
+	schema := &Schema{Version: SchemaVersion}
+	for _, f := range files {
+		schema.add(f)
+	}
+
+	// Indexes may be declared in a different file than their table, so
+	// they are attached once every file has been read.
+	for _, f := range files {
+		for _, ti := range f.indexes {
+			schema.addIndex(ti, diags)
+		}
+	}
+	schema.resolve(diags)
+
+	return schema
+}
+
+// sourceName is path relative to the working directory wd, with slashes.
+func sourceName(wd, path string) string {
+	if rel, err := filepath.Rel(wd, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// add adds the statements of f to s, except for its indexes.
+func (s *Schema) add(f *parsedFile) {
+	s.Schemas = append(s.Schemas, f.schemas...)
+	s.Tables = append(s.Tables, f.tables...)
+	s.Views = append(s.Views, f.views...)
+	s.ChangeStreams = append(s.ChangeStreams, f.streams...)
+	s.Sequences = append(s.Sequences, f.sequences...)
+}
+
+// addIndex attaches an index to its table, reporting unknown tables.
+func (s *Schema) addIndex(ti tableIndex, diags *Diagnostics) {
+	table := s.table(ti.table)
+	if table == nil {
+		diags.report(ti.source, ti.index.Pos, codeUnknownRef,
+			fmt.Errorf("index %s: unknown table %s", ti.index.Name, ti.table))
+		return
+	}
+	table.Indexes = append(table.Indexes, ti.index)
+}
+
+// resolve infers the columns of views and checks the references of
+// change streams and sequences, once every table is known.
+func (s *Schema) resolve(diags *Diagnostics) {
+	for _, view := range s.Views {
+		resolveView(s, view, diags)
+	}
+	for _, stream := range s.ChangeStreams {
+		resolveChangeStream(s, stream, diags)
+	}
+	resolveSequences(s, diags)
+	resolveForeignKeys(s, diags)
+}
+
+func parseFile(path, source string, diags *Diagnostics) *parsedFile {
+	parsed := &parsedFile{}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		diags.report(source, nil, codeIO, err)
+		return parsed
+	}
+
+	f := newSourceFile(source, string(src))
+	for _, stmt := range f.statements(diags) {
+		parsed.add(f, stmt, diags)
+	}
+	return parsed
+}
+
+// add parses a CREATE statement of f into p. Other statements are
+// ignored.
+func (p *parsedFile) add(f *sourceFile, stmt span, diags *Diagnostics) {
+	switch {
+	case createTableRegex.MatchString(stmt.text):
+		table, err := parseCreateTable(f, stmt)
+		if err != nil {
+			diags.report(f.name, f.pos(stmt.offset), codeSyntax, err)
+			return
+		}
+		table.Source = f.name
+		p.tables = append(p.tables, table)
+	case createViewRegex.MatchString(stmt.text):
+		view := parseCreateView(f, stmt)
+		view.Source = f.name
+		p.views = append(p.views, view)
+	case createChangeStreamRegex.MatchString(stmt.text):
+		stream, err := parseCreateChangeStream(f, stmt)
+		if err != nil {
+			diags.report(f.name, f.pos(stmt.offset), codeSyntax, err)
+			return
+		}
+		stream.Source = f.name
+		p.streams = append(p.streams, stream)
+	case createSequenceRegex.MatchString(stmt.text):
+		sequence := parseCreateSequence(f, stmt)
+		sequence.Source = f.name
+		p.sequences = append(p.sequences, sequence)
+	case createSchemaRegex.MatchString(stmt.text):
+		name := createSchemaRegex.FindStringSubmatch(stmt.text)[1]
+		p.schemas = append(p.schemas, unquoteIdent(name))
+	case createIndexRegex.MatchString(stmt.text):
+		tableName, index := parseCreateIndex(f, stmt)
+		p.indexes = append(p.indexes, tableIndex{table: tableName, source: f.name, index: index})
+	}
+}
+
+func parseCreateTable(f *sourceFile, stmt span) (*Table, error) {
+	matches := createTableRegex.FindStringSubmatchIndex(stmt.text)
+	open := matches[1] - 1
+	closing := closingParen(stmt.text, open)
+	if closing < 0 {
+		return nil, f.errorf(stmt.offset+open, codeSyntax, "unbalanced parentheses in CREATE TABLE")
+	}
+
+	table := &Table{Pos: f.pos(stmt.offset + matches[2])}
+	table.Schema, table.Name = splitName(stmt.text[matches[2]:matches[3]])
+	table.Quoted = nameQuoted(stmt.text[matches[2]:matches[3]])
+	table.SchemaQuoted = schemaQuoted(stmt.text[matches[2]:matches[3]])
+
+	values := map[string][]string{}
+	checks := map[string][]*Check{}
+	for _, def := range splitTopLevelSpans(stmt.sub(open+1, closing), ',') {
+		if def.text == "" {
+			continue
+		}
+		if constraintRegex.MatchString(def.text) {
+			if fk := parseForeignKey(f, def); fk != nil {
+				table.ForeignKeys = append(table.ForeignKeys, fk)
+			} else if pk := primaryKeysRegex.FindStringSubmatch(def.text); pk != nil {
+				// PostgreSQL declares the key among the columns.
+				table.PrimaryKey = parseKeyParts(pk[1])
+			} else if m := checkRegex.FindStringSubmatch(def.text); m != nil {
+				column, v, comparisons := parseCheck(m[1])
+				if v != nil {
+					values[column] = v
+				}
+				for column, c := range comparisons {
+					checks[column] = append(checks[column], c...)
+				}
+			}
+			continue
+		}
+		column, pk, err := parseColumn(f, def)
+		if err != nil {
+			return nil, err
+		}
+		if pk {
+			table.PrimaryKey = []*KeyPart{{Column: column.Name}}
+		}
+		if fk := column.references; fk != nil {
+			fk.Columns, column.references = []string{column.Name}, nil
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+		}
+		table.Columns = append(table.Columns, column)
+	}
+
+	for name, v := range values {
+		if column := table.column(name); column != nil {
+			column.Values = v
+		}
+	}
+	for name, c := range checks {
+		if column := table.column(name); column != nil {
+			column.Checks = append(column.Checks, c...)
+		}
+	}
+
+	trailer := stmt.text[closing+1:]
+	if pk := primaryKeysRegex.FindStringSubmatch(trailer); pk != nil {
+		table.PrimaryKey = parseKeyParts(pk[1])
+	} else {
+		// Only GoogleSQL declares the primary key after the columns.
+		table.Dialect = dialectPostgreSQL
+		// PostgreSQL key columns are NOT NULL, declared or not.
+		for _, part := range table.PrimaryKey {
+			if column := table.column(part.Column); column != nil {
+				column.NotNull = true
+			}
+		}
+	}
+	if il := interleaveRegex.FindStringSubmatch(trailer); il != nil {
+		table.Interleave = &Interleave{
+			Parent:   qualifiedName(il[1]),
+			OnDelete: strings.ToUpper(strings.Join(strings.Fields(il[2]), " ")),
+		}
+	}
+	table.DeletionPolicy = parseDeletionPolicy(trailer)
+
+	return table, nil
+}
+
+// parseColumn parses a column definition, and reports whether it declares
+// the column as the primary key, as PostgreSQL allows.
+func parseColumn(f *sourceFile, def span) (*Column, bool, error) {
+	name := columnNameRegex.FindStringSubmatch(def.text)
+	if name == nil {
+		return nil, false, f.errorf(def.offset, codeSyntax, "cannot parse column definition %q", def.text)
+	}
+
+	sqlType, rest := readType(normalizePGType(def.text[len(name[0]):]))
+	column := &Column{Name: unquoteIdent(name[1]), Quoted: nameQuoted(name[1]), Pos: f.pos(def.offset)}
+	sqlType, column.VectorLength = parseVectorType(sqlType)
+	column.Type = sqlType
+	if m := typeLengthRegex.FindStringSubmatch(sqlType); m != nil {
+		column.Type = strings.ToUpper(m[1])
+		column.Length = strings.ToUpper(m[2])
+	} else if !strings.Contains(sqlType, "<") && !strings.Contains(sqlType, ".") {
+		column.Type = strings.ToUpper(sqlType)
+	}
+
+	// Expressions are cut out of rest, so that NOT NULL is only looked
+	// for in the column's own clauses.
+	clause := func(re *regexp.Regexp, what string) (string, bool, error) {
+		loc := re.FindStringIndex(rest)
+		if loc == nil {
+			return "", false, nil
+		}
+		open := loc[1] - 1
+		closing := closingParen(rest, open)
+		if closing < 0 {
+			return "", false, f.errorf(def.offset, codeSyntax, "column %s: unbalanced %s", column.Name, what)
+		}
+		body := strings.TrimSpace(rest[open+1 : closing])
+		rest = rest[:loc[0]] + rest[closing+1:]
+		return body, true, nil
+	}
+
+	options, ok, err := clause(optionsRegex, "OPTIONS")
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		column.Options = parseOptions(options)
+	}
+	// A CHECK on the column, as in status STRING(MAX) CHECK (status IN
+	// ('a', 'b')), applies to it like one on the table.
+	for {
+		check, ok, err := clause(columnCheckRegex, "CHECK")
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			break
+		}
+		name, values, comparisons := parseCheck(check)
+		if values != nil && name == column.Name {
+			column.Values = values
+		}
+		column.Checks = append(column.Checks, comparisons[column.Name]...)
+	}
+	if column.Default, ok, err = clause(defaultRegex, "DEFAULT"); err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		if column.Default, err = bareDefault(&rest); err != nil {
+			return nil, false, f.errorf(def.offset, codeSyntax, "column %s: %v", column.Name, err)
+		}
+	}
+	if loc := generatedRegex.FindStringIndex(rest); loc != nil {
+		if column.Generated, _, err = clause(generatedRegex, "AS"); err != nil {
+			return nil, false, err
+		}
+		if m := storedRegex.FindStringIndex(rest[loc[0]:]); m != nil {
+			column.Stored = true
+			rest = rest[:loc[0]] + rest[loc[0]+m[1]:]
+		}
+	}
+	if column.references = cutReferences(&rest); column.references != nil {
+		column.references.Pos = column.Pos
+	}
+	column.Sequence = defaultSequence(column.Default)
+	column.NotNull = notNullRegex.MatchString(rest)
+	column.Hidden = hiddenRegex.MatchString(rest)
+
+	return column, columnPKRegex.MatchString(rest), nil
+}
+
+// bareDefault cuts a PostgreSQL DEFAULT without parentheses, such as
+// DEFAULT now() or DEFAULT 0, out of rest and returns its expression.
+func bareDefault(rest *string) (string, error) {
+	m := bareDefaultRegex.FindStringSubmatchIndex(*rest)
+	if m == nil {
+		return "", nil
+	}
+	start, end := m[2], m[3]
+	if (*rest)[end-1] == '(' {
+		closing := closingParen(*rest, end-1)
+		if closing < 0 {
+			return "", fmt.Errorf("unbalanced DEFAULT")
+		}
+		end = closing + 1
+	}
+	expr := (*rest)[start:end]
+	*rest = (*rest)[:m[0]] + (*rest)[end:]
+	return expr, nil
+}
+
+// parseDeletionPolicy parses ROW DELETION POLICY (OLDER_THAN(column,
+// INTERVAL n DAY)), or PostgreSQL's TTL INTERVAL 'n days' ON column.
+func parseDeletionPolicy(s string) *DeletionPolicy {
+	if m := deletionPolicyRegex.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[2])
+		return &DeletionPolicy{Column: unquoteIdent(m[1]), Days: days}
+	}
+	if m := pgTTLRegex.FindStringSubmatch(s); m != nil {
+		days, _ := strconv.Atoi(m[1])
+		return &DeletionPolicy{Column: unquoteIdent(m[2]), Days: days}
+	}
+	return nil
+}
+
+func parseCreateIndex(f *sourceFile, stmt span) (string, *Index) {
+	m := createIndexRegex.FindStringSubmatchIndex(stmt.text)
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return stmt.text[m[2*i]:m[2*i+1]]
+	}
+
+	index := &Index{
+		Name:         qualifiedName(group(4)),
+		Pos:          f.pos(stmt.offset + m[8]),
+		Unique:       group(1) != "",
+		NullFiltered: group(2) != "",
+		Search:       strings.EqualFold(strings.TrimSpace(group(3)), "SEARCH"),
+		Vector:       strings.EqualFold(strings.TrimSpace(group(3)), "VECTOR"),
+		Columns:      parseKeyParts(group(6)),
+	}
+	if storing := storingRegex.FindStringSubmatch(group(7)); storing != nil {
+		for _, c := range strings.Split(storing[1], ",") {
+			index.Storing = append(index.Storing, unquoteIdent(c))
+		}
+	}
+	if loc := optionsRegex.FindStringIndex(group(7)); loc != nil {
+		if closing := closingParen(group(7), loc[1]-1); closing >= 0 {
+			index.Options = parseOptions(group(7)[loc[1]:closing])
+		}
+	}
+	if parent := indexParentRegex.FindStringSubmatch(group(7)); parent != nil {
+		index.Interleave = qualifiedName(parent[1])
+	}
+	return qualifiedName(group(5)), index
+}
+
+// readType splits a column definition remainder into the SQL type and
+// whatever follows it, keeping ARRAY<...>/STRUCT<...> and length suffixes
+// together.
+func readType(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	top := topLevel(s)
+	end := len(s)
+	for i := 0; i < len(s); i++ {
+		if top[i] && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+			if next := strings.TrimLeft(s[i:], " \t\r\n"); strings.HasPrefix(next, "(") {
+				continue
+			}
+			end = i
+			break
+		}
+	}
+	sqlType := strings.Join(strings.Fields(s[:end]), " ")
+	sqlType = strings.ReplaceAll(sqlType, " (", "(")
+	return sqlType, s[end:]
+}
+
+func parseKeyParts(s string) []*KeyPart {
+	var parts []*KeyPart
+	for _, key := range strings.Split(s, ",") {
+		f := strings.Fields(key)
+		if len(f) == 0 {
+			continue
+		}
+		parts = append(parts, &KeyPart{
+			Column: unquoteIdent(f[0]),
+			Desc:   len(f) > 1 && strings.EqualFold(f[1], "DESC"),
+		})
+	}
+	return parts
+}
+
+func parseOptions(s string) map[string]string {
+	options := map[string]string{}
+	for _, opt := range splitTopLevel(s, ',') {
+		k, v, ok := strings.Cut(opt, "=")
+		if !ok {
+			continue
+		}
+		options[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return options
+}
+
+// parseCheck parses the expression of a CHECK constraint: either a column
+// IN a list of literals, returned as the column and its values, or
+// comparisons of columns with literals.
+func parseCheck(expr string) (string, []string, map[string][]*Check) {
+	if m := inListRegex.FindStringSubmatch(unwrapParens(expr)); m != nil {
+		if values, ok := parseLiterals(m[2]); ok {
+			return unquoteIdent(m[1]), values, nil
+		}
+		return "", nil, nil
+	}
+	return "", nil, parseComparisons(expr)
+}
+
+// parseLiterals parses a list of string and integer literals, as in
+// CHECK (status IN ('active', 'archived')). It fails on anything else.
+func parseLiterals(s string) ([]string, bool) {
+	var values []string
+	for _, lit := range splitTopLevel(s, ',') {
+		switch {
+		case intLiteralRegex.MatchString(lit):
+			values = append(values, lit)
+		case len(lit) >= 2 && (lit[0] == '\'' || lit[0] == '"') && lit[len(lit)-1] == lit[0]:
+			values = append(values, unescapeLiteral(lit[1:len(lit)-1]))
+		default:
+			return nil, false
+		}
+	}
+	return values, len(values) > 0
+}
+
+func unescapeLiteral(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// flippedOps turn "literal op column" around.
+var flippedOps = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "=": "=", "!=": "!="}
+
+// parseComparisons parses a CHECK expression made of comparisons of
+// columns with literals joined by AND, as in CHECK (age >= 0 AND age < 150).
+// Anything more involved is left to Spanner, and nothing is returned.
+func parseComparisons(expr string) map[string][]*Check {
+	expr = unwrapParens(expr)
+	checks := map[string][]*Check{}
+	if terms := splitAnd(expr); len(terms) > 1 {
+		for _, term := range terms {
+			sub := parseComparisons(term)
+			if sub == nil {
+				return nil
+			}
+			for column, c := range sub {
+				checks[column] = append(checks[column], c...)
+			}
+		}
+		return checks
+	}
+
+	m := comparisonRegex.FindStringSubmatch(expr)
+	if m == nil {
+		return nil
+	}
+	column, op, value := m[1], m[2], m[3]
+	if op == "<>" {
+		op = "!="
+	}
+	if isLiteral(column) {
+		column, op, value = value, flippedOps[op], column
+	}
+	if isLiteral(column) || !isLiteral(value) {
+		return nil
+	}
+	checks[unquoteIdent(column)] = []*Check{{Op: op, Value: value}}
+	return checks
+}
+
+// splitAnd splits s at the ANDs outside of parentheses and strings.
+func splitAnd(s string) []string {
+	top := topLevel(s)
+	var terms []string
+	start := 0
+	for _, loc := range andRegex.FindAllStringIndex(s, -1) {
+		if top[loc[0]] {
+			terms = append(terms, s[start:loc[0]])
+			start = loc[1]
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func isLiteral(s string) bool {
+	return s[0] == '\'' || s[0] == '"' || numLiteralRegex.MatchString(s)
+}
+
+// unwrapParens strips parentheses around the whole of s.
+func unwrapParens(s string) string {
+	s = strings.TrimSpace(s)
+	for strings.HasPrefix(s, "(") && closingParen(s, 0) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes files, keyed by slash-separated paths, into a new
+// directory and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// parseSQL parses sql as the schema file of a model directory.
+func parseSQL(t *testing.T, sql string) (*Schema, *Diagnostics) {
+	t.Helper()
+	dir := writeFiles(t, map[string]string{"models/m/schema.sql": sql})
+	diags := &Diagnostics{}
+	return parseSchema([]string{filepath.Join(dir, "models", "m", "schema.sql")}, 1, diags), diags
+}
+
+// diagnosticsText prints diags the way humans see them.
+func diagnosticsText(diags *Diagnostics) string {
+	var b bytes.Buffer
+	diags.print(&b, "human")
+	return b.String()
+}
+
+func TestSchemaJSONRoundTrip(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE SCHEMA sales;
+
+CREATE TABLE Singers (
+  SingerId INT64 NOT NULL,
+  Name STRING(1024),
+  Tags ARRAY<STRING(MAX)>,
+  Status STRING(MAX) NOT NULL,
+  CONSTRAINT status_values CHECK (Status IN ('active', 'retired')),
+) PRIMARY KEY (SingerId);
+
+CREATE TABLE sales.Albums (
+  SingerId INT64 NOT NULL,
+  AlbumId INT64 NOT NULL,
+  Title STRING(MAX),
+  Price NUMERIC,
+  CONSTRAINT positive CHECK (Price >= 0),
+) PRIMARY KEY (SingerId, AlbumId DESC),
+  INTERLEAVE IN PARENT Singers ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX AlbumsByTitle ON sales.Albums(Title) STORING (Price);
+
+CREATE VIEW SingerNames SQL SECURITY INVOKER AS SELECT s.SingerId, s.Name FROM Singers AS s;
+`)
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+
+	var b bytes.Buffer
+	if err := writeSchemaJSON(&b, schema); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readSchemaJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, schema) {
+		var g bytes.Buffer
+		writeSchemaJSON(&g, got)
+		t.Errorf("schema changed in a round trip:\n%s\nwant:\n%s", g.String(), b.String())
+	}
+
+	albums := got.table("sales.Albums")
+	if albums == nil {
+		t.Fatal("sales.Albums missing")
+	}
+	if albums.Interleave == nil || albums.Interleave.Parent != "Singers" || albums.Interleave.OnDelete != "CASCADE" {
+		t.Errorf("Interleave = %+v", albums.Interleave)
+	}
+	if len(albums.PrimaryKey) != 2 || !albums.PrimaryKey[1].Desc {
+		t.Errorf("PrimaryKey = %+v", albums.PrimaryKey)
+	}
+	if len(albums.Indexes) != 1 || !albums.Indexes[0].Unique || albums.Indexes[0].Storing[0] != "Price" {
+		t.Errorf("Indexes = %+v", albums.Indexes)
+	}
+	if c := albums.column("Price").Checks; len(c) != 1 || *c[0] != (Check{Op: ">=", Value: "0"}) {
+		t.Errorf("Price checks = %+v", c)
+	}
+	if v := got.table("Singers").column("Status").Values; !reflect.DeepEqual(v, []string{"active", "retired"}) {
+		t.Errorf("Status values = %q", v)
+	}
+	if len(got.Views) != 1 || len(got.Views[0].Columns) != 2 {
+		t.Errorf("Views = %+v", got.Views)
+	}
+}
+
+func TestReadSchemaJSONVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "tables": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readSchemaJSON(path); err == nil || !strings.Contains(err.Error(), "unsupported schema version 99") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestParseColumn(t *testing.T) {
+	tests := []struct {
+		def  string
+		want Column
+		pk   bool
+	}{
+		{
+			def:  "Id STRING(36) NOT NULL",
+			want: Column{Name: "Id", Type: "STRING", Length: "36", NotNull: true},
+		},
+		{
+			def:  "`Order` INT64",
+			want: Column{Name: "Order", Quoted: true, Type: "INT64"},
+		},
+		{
+			def:  "Tags ARRAY<STRING(MAX)> NOT NULL",
+			want: Column{Name: "Tags", Type: "ARRAY<STRING(MAX)>", NotNull: true},
+		},
+		{
+			def:  "Count INT64 NOT NULL DEFAULT (0)",
+			want: Column{Name: "Count", Type: "INT64", NotNull: true, Default: "0"},
+		},
+		{
+			def:  "Note STRING(MAX) DEFAULT ('NOT NULL')",
+			want: Column{Name: "Note", Type: "STRING", Length: "MAX", Default: "'NOT NULL'"},
+		},
+		{
+			def:  "Id INT64 DEFAULT (GET_NEXT_SEQUENCE_VALUE(SEQUENCE Seq)) NOT NULL",
+			want: Column{Name: "Id", Type: "INT64", NotNull: true, Default: "GET_NEXT_SEQUENCE_VALUE(SEQUENCE Seq)", Sequence: "Seq"},
+		},
+		{
+			def:  "UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp = true)",
+			want: Column{Name: "UpdatedAt", Type: "TIMESTAMP", NotNull: true, Options: map[string]string{"allow_commit_timestamp": "true"}},
+		},
+		{
+			def:  "FullName STRING(MAX) AS (ARRAY_TO_STRING([First, Last], ' ')) STORED",
+			want: Column{Name: "FullName", Type: "STRING", Length: "MAX", Generated: "ARRAY_TO_STRING([First, Last], ' ')", Stored: true},
+		},
+		{
+			def:  "Lower STRING(MAX) AS (LOWER(Name))",
+			want: Column{Name: "Lower", Type: "STRING", Length: "MAX", Generated: "LOWER(Name)"},
+		},
+		{
+			def:  "Tokens TOKENLIST AS (TOKENIZE_FULLTEXT(Body)) HIDDEN",
+			want: Column{Name: "Tokens", Type: "TOKENLIST", Generated: "TOKENIZE_FULLTEXT(Body)", Hidden: true},
+		},
+		{
+			def:  "created_at timestamptz NOT NULL DEFAULT now()",
+			want: Column{Name: "created_at", Type: "TIMESTAMPTZ", NotNull: true, Default: "now()"},
+		},
+		{
+			def:  "id bigint PRIMARY KEY",
+			want: Column{Name: "id", Type: "BIGINT"},
+			pk:   true,
+		},
+	}
+	for _, tt := range tests {
+		f := newSourceFile("schema.sql", tt.def)
+		column, pk, err := parseColumn(f, span{text: tt.def})
+		if err != nil {
+			t.Errorf("%s: %v", tt.def, err)
+			continue
+		}
+		column.Pos = nil
+		if !reflect.DeepEqual(*column, tt.want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", tt.def, *column, tt.want)
+		}
+		if pk != tt.pk {
+			t.Errorf("%s: primary key = %v, want %v", tt.def, pk, tt.pk)
+		}
+	}
+}
+
+func TestParseColumnErrors(t *testing.T) {
+	for _, def := range []string{
+		"Count INT64 DEFAULT (1",
+		"Total INT64 AS (a + (b)",
+		"123 INT64",
+	} {
+		f := newSourceFile("schema.sql", def)
+		if _, _, err := parseColumn(f, span{text: def}); err == nil {
+			t.Errorf("%s: no error", def)
+		} else if d, ok := err.(*Diagnostic); !ok || d.Code != codeSyntax || d.Line != 1 {
+			t.Errorf("%s: err = %v", def, err)
+		}
+	}
+}
+
+func TestParseColumnChecks(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  status STRING(16) NOT NULL CHECK (status IN ('active', 'in-review')),
+  age INT64 CONSTRAINT adult CHECK (age >= 18) CHECK (age < 150),
+  score INT64 CHECK (score <= 100) NOT NULL,
+  level INT64 CHECK (level IN (1, 2, 3)),
+  CONSTRAINT score_positive CHECK (score > 0),
+) PRIMARY KEY (id);
+`)
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	users := schema.table("users")
+	if v := users.column("status").Values; !reflect.DeepEqual(v, []string{"active", "in-review"}) {
+		t.Errorf("status values = %q", v)
+	}
+	if v := users.column("level").Values; !reflect.DeepEqual(v, []string{"1", "2", "3"}) {
+		t.Errorf("level values = %q", v)
+	}
+	checks := func(name string) []Check {
+		var c []Check
+		for _, check := range users.column(name).Checks {
+			c = append(c, *check)
+		}
+		return c
+	}
+	if c := checks("age"); !reflect.DeepEqual(c, []Check{{">=", "18"}, {"<", "150"}}) {
+		t.Errorf("age checks = %+v", c)
+	}
+	if c := checks("score"); !reflect.DeepEqual(c, []Check{{"<=", "100"}, {">", "0"}}) {
+		t.Errorf("score checks = %+v", c)
+	}
+	if !users.column("score").NotNull {
+		t.Error("score is not NOT NULL")
+	}
+}
+
+func TestParsePostgreSQLQuotedNames(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE SCHEMA Sales;
+
+CREATE TABLE Sales."Accounts" (
+  AccountId varchar(36) NOT NULL,
+  "DisplayName" text,
+  PRIMARY KEY (AccountId)
+);
+`)
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	accounts := schema.table("Sales.Accounts")
+	if accounts == nil {
+		t.Fatalf("tables = %+v", schema.Tables)
+	}
+	if accounts.Dialect != dialectPostgreSQL || !accounts.Quoted || accounts.SchemaQuoted {
+		t.Errorf("accounts = %+v", accounts)
+	}
+	if c := accounts.column("AccountId"); c == nil || c.Quoted {
+		t.Errorf("AccountId = %+v", c)
+	}
+	if c := accounts.column("DisplayName"); c == nil || !c.Quoted {
+		t.Errorf("DisplayName = %+v", c)
+	}
+	if got := tableName(accounts); got != "sales.Accounts" {
+		t.Errorf("tableName = %s, want sales.Accounts", got)
+	}
+	for name, want := range map[string]string{"AccountId": "accountid", "DisplayName": "DisplayName"} {
+		if got := columnName(accounts, name); got != want {
+			t.Errorf("columnName(%s) = %s, want %s", name, got, want)
+		}
+	}
+	if accounts.PrimaryKey[0].Column != "AccountId" || columnName(accounts, accounts.PrimaryKey[0].Column) != "accountid" {
+		t.Errorf("PrimaryKey = %+v", accounts.PrimaryKey[0])
+	}
+}
+
+func TestParsePostgreSQLDialect(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE TABLE users (
+  id bigint PRIMARY KEY,
+  name character varying(40) NOT NULL,
+  score double precision,
+  updated timestamp with time zone,
+  tags varchar(10)[],
+  counts bigint [] NOT NULL
+);
+
+CREATE TABLE memberships (
+  user_id bigint,
+  group_id bigint,
+  PRIMARY KEY (user_id, group_id)
+);
+
+CREATE TABLE groups (
+  id INT64,
+) PRIMARY KEY (id);
+`)
+	if len(diags.list) != 0 {
+		t.Fatalf("unexpected diagnostics:\n%s", diagnosticsText(diags))
+	}
+	users, memberships, groups := schema.table("users"), schema.table("memberships"), schema.table("groups")
+	if users.Dialect != dialectPostgreSQL || memberships.Dialect != dialectPostgreSQL || groups.Dialect != "" {
+		t.Errorf("dialects = %q, %q, %q", users.Dialect, memberships.Dialect, groups.Dialect)
+	}
+	// Multi-word and array types are read as one type.
+	for name, want := range map[string]string{
+		"name":    "VARCHAR",
+		"score":   "FLOAT8",
+		"updated": "TIMESTAMPTZ",
+		"tags":    "ARRAY<VARCHAR(10)>",
+		"counts":  "ARRAY<BIGINT>",
+	} {
+		if c := users.column(name); c == nil || c.Type != want {
+			t.Errorf("%s = %+v, want type %s", name, c, want)
+		}
+	}
+	// Key columns are NOT NULL in PostgreSQL, not in GoogleSQL.
+	for _, c := range []*Column{users.column("id"), memberships.column("user_id"), memberships.column("group_id")} {
+		if !c.NotNull {
+			t.Errorf("key column %s is nullable", c.Name)
+		}
+	}
+	if groups.column("id").NotNull {
+		t.Error("GoogleSQL key column id is NOT NULL")
+	}
+
+	schema.setDialect(dialectGoogleSQL)
+	if users.Dialect != "" {
+		t.Errorf("dialect after setDialect = %q", users.Dialect)
+	}
+	schema.setDialect(dialectPostgreSQL)
+	if groups.Dialect != dialectPostgreSQL {
+		t.Errorf("dialect after setDialect = %q", groups.Dialect)
+	}
+}
+
+func TestPGType(t *testing.T) {
+	tests := map[string]string{
+		"BIGINT":             "INT64",
+		"varchar(40)":        "STRING(40)",
+		"VARCHAR":            "STRING",
+		"text":               "STRING",
+		"boolean":            "BOOL",
+		"FLOAT8":             "FLOAT64",
+		"real":               "FLOAT32",
+		"bytea":              "BYTES",
+		"TIMESTAMPTZ":        "TIMESTAMP",
+		"numeric":            "PG_NUMERIC",
+		"jsonb":              "PG_JSONB",
+		"ARRAY<VARCHAR(10)>": "ARRAY<STRING(10)>",
+		"GEOGRAPHY":          "GEOGRAPHY",
+	}
+	for in, want := range tests {
+		if got := pgType(in); got != want {
+			t.Errorf("pgType(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestParseChangeStreams(t *testing.T) {
+	schema, diags := parseSQL(t, `
+CREATE TABLE users (
+  id STRING(36) NOT NULL,
+  name STRING(MAX),
+  email STRING(MAX),
+) PRIMARY KEY (id);
+
+CREATE TABLE orders (
+  id STRING(36) NOT NULL,
+) PRIMARY KEY (id);
+
+CREATE CHANGE STREAM UserNames FOR users(name, `+"`email`"+`), orders()
+  OPTIONS (retention_period = '7d', value_capture_type = 'NEW_ROW');
+CREATE CHANGE STREAM Everything FOR ALL;
+CREATE CHANGE STREAM Orders FOR orders;
+CREATE CHANGE STREAM Nothing;
+CREATE CHANGE STREAM Broken FOR accounts, users(nickname);
+`)
+	if diags.hasErrors() {
+		t.Fatalf("unexpected errors:\n%s", diagnosticsText(diags))
+	}
+	if len(schema.ChangeStreams) != 5 {
+		t.Fatalf("change streams = %+v", schema.ChangeStreams)
+	}
+	names := schema.ChangeStreams[0]
+	want := []*WatchedTable{
+		{Table: "users", Columns: []string{"name", "email"}},
+		{Table: "orders"},
+	}
+	if names.Name != "UserNames" || !reflect.DeepEqual(names.Tables, want) {
+		t.Errorf("UserNames = %+v, tables %+v", names, names.Tables)
+	}
+	if names.Options["retention_period"] != "'7d'" || names.Options["value_capture_type"] != "'NEW_ROW'" {
+		t.Errorf("UserNames options = %q", names.Options)
+	}
+	if !schema.ChangeStreams[1].All || schema.ChangeStreams[4].Pos.Line != 17 {
+		t.Errorf("change streams = %+v", schema.ChangeStreams)
+	}
+
+	// Unknown tables and columns are warnings: the stream is still kept.
+	for _, want := range []string{"change stream Broken: unknown table accounts", "change stream Broken: unknown column users.nickname"} {
+		if !hasDiagnostic(diags, codeUnknownRef, want) {
+			t.Errorf("no warning %q:\n%s", want, diagnosticsText(diags))
+		}
+	}
+	if len(diags.list) != 2 {
+		t.Errorf("diagnostics:\n%s", diagnosticsText(diags))
+	}
+
+	// An empty column list watches the key columns only, no list every
+	// column.
+	users, orders := schema.table("users"), schema.table("orders")
+	tests := []struct {
+		stream  *ChangeStream
+		table   *Table
+		columns []string
+		ok      bool
+	}{
+		{schema.ChangeStreams[0], users, []string{"name", "email"}, true},
+		{schema.ChangeStreams[0], orders, []string{}, true},
+		{schema.ChangeStreams[1], users, nil, true},
+		{schema.ChangeStreams[2], orders, nil, true},
+		{schema.ChangeStreams[2], users, nil, false},
+		{schema.ChangeStreams[3], users, nil, false},
+	}
+	for _, tt := range tests {
+		if columns, ok := tt.stream.watches(tt.table); !reflect.DeepEqual(columns, tt.columns) || ok != tt.ok {
+			t.Errorf("%s watches %s = %q, %v, want %q, %v", tt.stream.Name, tt.table.Name, columns, ok, tt.columns, tt.ok)
+		}
+	}
+
+	schema.models()
+	var watching []string
+	for _, s := range orders.changeStreams {
+		watching = append(watching, s.name)
+	}
+	if want := []string{"UserNames", "Everything", "Orders"}; !reflect.DeepEqual(watching, want) {
+		t.Errorf("streams watching orders = %q, want %q", watching, want)
+	}
+}
+
+func TestParseDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		trailer string
+		want    *DeletionPolicy
+	}{
+		{" PRIMARY KEY (id), ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 DAY))", &DeletionPolicy{Column: "created", Days: 30}},
+		{" PRIMARY KEY (id),\n  row deletion policy ( older_than ( `Created` , interval 7 day ) )", &DeletionPolicy{Column: "Created", Days: 7}},
+		{" TTL INTERVAL '5 days' ON created_at", &DeletionPolicy{Column: "created_at", Days: 5}},
+		{` TTL INTERVAL '1 day' ON "CreatedAt"`, &DeletionPolicy{Column: "CreatedAt", Days: 1}},
+		{" PRIMARY KEY (id)", nil},
+		// Only whole days can be declared.
+		{" PRIMARY KEY (id), ROW DELETION POLICY (OLDER_THAN(created, INTERVAL 30 HOUR))", nil},
+	}
+	for _, tt := range tests {
+		if got := parseDeletionPolicy(tt.trailer); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDeletionPolicy(%q) = %+v, want %+v", tt.trailer, got, tt.want)
+		}
+	}
+}
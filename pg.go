@@ -0,0 +1,125 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Dialects of Spanner databases. GoogleSQL is the default and is left out
+// of Table.Dialect.
+const (
+	dialectGoogleSQL  = "googlesql"
+	dialectPostgreSQL = "postgresql"
+)
+
+var (
+	pgMultiWordTypeRegex = regexp.MustCompile(`(?i)^(double\s+precision|character\s+varying|timestamp\s+with\s+time\s+zone)\b`)
+	pgArrayRegex         = regexp.MustCompile(`^(.+?)\s*\[\s*\]$`)
+)
+
+// pgMultiWordTypes are the PostgreSQL types spelled with several words,
+// replaced by their one-word aliases so that the type reads like any other.
+var pgMultiWordTypes = map[string]string{
+	"DOUBLE PRECISION":         "FLOAT8",
+	"CHARACTER VARYING":        "VARCHAR",
+	"TIMESTAMP WITH TIME ZONE": "TIMESTAMPTZ",
+}
+
+// pgTypes are the GoogleSQL types PostgreSQL-dialect columns are mapped as.
+// NUMERIC and JSONB have types of their own in the Spanner client.
+var pgTypes = map[string]string{
+	"BIGINT":      "INT64",
+	"INT8":        "INT64",
+	"VARCHAR":     "STRING",
+	"TEXT":        "STRING",
+	"BOOLEAN":     "BOOL",
+	"BOOL":        "BOOL",
+	"FLOAT8":      "FLOAT64",
+	"REAL":        "FLOAT32",
+	"FLOAT4":      "FLOAT32",
+	"BYTEA":       "BYTES",
+	"DATE":        "DATE",
+	"TIMESTAMPTZ": "TIMESTAMP",
+	"NUMERIC":     "PG_NUMERIC",
+	"DECIMAL":     "PG_NUMERIC",
+	"JSONB":       "PG_JSONB",
+}
+
+// normalizePGType rewrites the PostgreSQL spellings of a column type at the
+// start of def, multi-word names and type[] arrays, into one token that
+// readType keeps together: ARRAY<VARCHAR(10)> for varchar(10)[].
+func normalizePGType(def string) string {
+	def = strings.TrimLeft(def, " \t\r\n")
+	if m := pgMultiWordTypeRegex.FindString(def); m != "" {
+		def = pgMultiWordTypes[normalizeSQLType(m)] + def[len(m):]
+	}
+	sqlType, rest := readType(def)
+	if m := pgArrayRegex.FindStringSubmatch(sqlType); m != nil {
+		return "ARRAY<" + strings.ToUpper(m[1]) + ">" + rest
+	}
+	if next := strings.TrimLeft(rest, " \t"); strings.HasPrefix(next, "[") {
+		// varchar(10) [] with a space.
+		if end := strings.Index(next, "]"); end >= 0 {
+			return "ARRAY<" + strings.ToUpper(sqlType) + ">" + next[end+1:]
+		}
+	}
+	return def
+}
+
+// pgColumn returns column with its type replaced by the GoogleSQL type it
+// is mapped as.
+func pgColumn(column *Column) *Column {
+	c := *column
+	c.Type = pgType(column.Type)
+	return &c
+}
+
+func pgType(t string) string {
+	if inner, ok := strings.CutPrefix(t, "ARRAY<"); ok {
+		return "ARRAY<" + pgType(strings.TrimSuffix(inner, ">")) + ">"
+	}
+	base, args, _ := strings.Cut(t, "(")
+	mapped, ok := pgTypes[strings.ToUpper(strings.TrimSpace(base))]
+	if !ok {
+		return t
+	}
+	if args != "" {
+		return mapped + "(" + args
+	}
+	return mapped
+}
+
+// pgName is the name PostgreSQL resolves an identifier declared as name
+// to: unquoted names are folded to lower case.
+func pgName(name string, quoted bool) string {
+	if quoted {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// columnName is the name the generated code uses for the column name of
+// table, folded to lower case in PostgreSQL unless declared quoted.
+func columnName(table *Table, name string) string {
+	if table.Dialect != dialectPostgreSQL {
+		return name
+	}
+	c := table.column(name)
+	return pgName(name, c != nil && c.Quoted)
+}
+
+// setDialect overrides the detected dialect of every table.
+func (s *Schema) setDialect(dialect string) {
+	if dialect == "" {
+		return
+	}
+	if dialect == dialectGoogleSQL {
+		dialect = ""
+	}
+	for _, t := range s.Tables {
+		t.Dialect = dialect
+	}
+	for _, v := range s.Views {
+		v.Dialect = dialect
+	}
+}
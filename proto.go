@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ProtoPackage is the Go package generated for a proto package. In the
+// config it is either just the import path, or an object also listing
+// the enums of the package, which cannot be told apart from messages when
+// a column type is the bare name from a proto bundle.
+type ProtoPackage struct {
+	Import string   `json:"import"`
+	Enums  []string `json:"enums,omitempty"`
+}
+
+func (p *ProtoPackage) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &p.Import); err == nil {
+		return nil
+	}
+	type protoPackage ProtoPackage
+	return json.Unmarshal(data, (*protoPackage)(p))
+}
+
+func (p ProtoPackage) validate() error {
+	if p.Import == "" {
+		return fmt.Errorf("missing Go import path")
+	}
+	return nil
+}
+
+// goPackage is the name the generated code refers to the package by.
+func (p ProtoPackage) goPackage() string {
+	if name, _, ok := strings.Cut(p.Import, " "); ok {
+		return name
+	}
+	return path.Base(p.Import)
+}
+
+func (p ProtoPackage) isEnum(name string) bool {
+	for _, e := range p.Enums {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// protoType maps the proto message or enum name, of the given kind if
+// known, to the Go type protoc-gen-go generates for it. Messages are
+// pointers; enums are pointers when nullable. Nullable columns get a codec
+// because the Spanner client cannot decode NULL into either.
+func (r *typeRegistry) protoType(name, kind string, nullable bool) (TypeMapping, bool) {
+	var pkg string
+	for p := range r.protos {
+		if strings.HasPrefix(name, p+".") && len(p) > len(pkg) {
+			pkg = p
+		}
+	}
+	if pkg == "" {
+		return TypeMapping{}, false
+	}
+	pp := r.protos[pkg]
+	local := strings.TrimPrefix(name, pkg+".")
+	enum := kind == "ENUM" || (kind == "" && pp.isEnum(local))
+
+	m := TypeMapping{
+		Type:   pp.goPackage() + "." + strings.ReplaceAll(local, ".", "_"),
+		Import: pp.Import,
+	}
+	if !enum || nullable {
+		m.Type = "*" + m.Type
+	}
+	if nullable {
+		m.Codec = codecProto
+		if enum {
+			m.Codec = codecProtoEnum
+		}
+	}
+	return m, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoundTrip is a column value in its Spanner wire encoding, written as Go
+// expressions for the generated round-trip test.
+type RoundTrip struct {
+	Type  string // *spannerpb.Type
+	Value string // *structpb.Value
+}
+
+// wireSamples are non-NULL values of the scalar types, chosen so that
+// decoding and encoding them again gives the same wire value.
+var wireSamples = map[string]string{
+	"INT64":      `structpb.NewStringValue("42")`,
+	"STRING":     `structpb.NewStringValue("a")`,
+	"BOOL":       `structpb.NewBoolValue(true)`,
+	"FLOAT64":    `structpb.NewNumberValue(1.5)`,
+	"FLOAT32":    `structpb.NewNumberValue(1.5)`,
+	"BYTES":      `structpb.NewStringValue("YQ==")`,
+	"TIMESTAMP":  `structpb.NewStringValue("2024-01-02T03:04:05.123456789Z")`,
+	"DATE":       `structpb.NewStringValue("2024-01-02")`,
+	"NUMERIC":    `structpb.NewStringValue("1.500000000")`,
+	"JSON":       `structpb.NewStringValue("{\"a\":1}")`,
+	"PG_NUMERIC": `structpb.NewStringValue("1.5")`,
+	"PG_JSONB":   `structpb.NewStringValue("{\"a\":1}")`,
+}
+
+// wireTypes are the types that are not just a TypeCode.
+var wireTypes = map[string]string{
+	"PG_NUMERIC": "&sppb.Type{Code: sppb.TypeCode_NUMERIC, TypeAnnotation: sppb.TypeAnnotationCode_PG_NUMERIC}",
+	"PG_JSONB":   "&sppb.Type{Code: sppb.TypeCode_JSON, TypeAnnotation: sppb.TypeAnnotationCode_PG_JSONB}",
+}
+
+// roundTrips returns the values a column of type t is tested with: a
+// sample and, unless the column is NOT NULL, NULL. Types without samples,
+// such as STRUCT, are not tested.
+func roundTrips(t *sqlType, notNull bool) []RoundTrip {
+	typ, value, ok := wireSample(t)
+	if !ok {
+		return nil
+	}
+	trips := []RoundTrip{{Type: typ, Value: value}}
+	if !notNull {
+		trips = append(trips, RoundTrip{Type: typ, Value: "structpb.NewNullValue()"})
+	}
+	return trips
+}
+
+// wireSample returns the type and a sample value of t. Arrays get a NULL
+// element besides the sample one.
+func wireSample(t *sqlType) (string, string, bool) {
+	if t.Elem != nil {
+		typ, value, ok := wireSample(t.Elem)
+		if !ok {
+			return "", "", false
+		}
+		return fmt.Sprintf("&sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: %s}", typ),
+			fmt.Sprintf("structpb.NewListValue(&structpb.ListValue{Values: []*structpb.Value{%s, structpb.NewNullValue()}})", value),
+			true
+	}
+	value, ok := wireSamples[t.Base]
+	if !ok || t.Fields != nil || t.Param != "" {
+		return "", "", false
+	}
+	if typ, ok := wireTypes[t.Base]; ok {
+		return typ, value, true
+	}
+	return fmt.Sprintf("&sppb.Type{Code: sppb.TypeCode_%s}", t.Base), value, true
+}
+
+// enumRoundTrips tests an enum column with the first of its values.
+func enumRoundTrips(column *Column) []RoundTrip {
+	trips := roundTrips(&sqlType{Base: strings.ToUpper(column.Type)}, column.NotNull)
+	if len(trips) > 0 {
+		trips[0].Value = fmt.Sprintf("structpb.NewStringValue(%q)", column.Values[0])
+	}
+	return trips
+}
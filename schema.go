@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SchemaVersion is bumped whenever the JSON representation of Schema changes
+// in a way that is not backwards compatible.
+const SchemaVersion = 1
+
+// Schema is the intermediate representation of all parsed .sql files. It is
+// what the generator renders from and what `model-gen schema` emits, so other
+// tools can build on top of the parser.
+type Schema struct {
+	Version int `json:"version"`
+	// Schemas are the named schemas created with CREATE SCHEMA.
+	Schemas []string `json:"schemas,omitempty"`
+	Tables  []*Table `json:"tables"`
+	Views   []*View  `json:"views,omitempty"`
+	// ChangeStreams are the CREATE CHANGE STREAM statements.
+	ChangeStreams []*ChangeStream `json:"change_streams,omitempty"`
+	Sequences     []*Sequence     `json:"sequences,omitempty"`
+}
+
+type Table struct {
+	Name string `json:"name"`
+	// Quoted is set if the name was declared quoted, which PostgreSQL
+	// keeps as is instead of folding it to lower case.
+	Quoted bool `json:"quoted,omitempty"`
+	// Schema is the named schema of the table, empty for the default one.
+	// SchemaQuoted is set if it was declared quoted.
+	Schema       string      `json:"schema,omitempty"`
+	SchemaQuoted bool        `json:"schema_quoted,omitempty"`
+	Source       string      `json:"source,omitempty"`
+	Pos          *Pos        `json:"pos,omitempty"`
+	Columns      []*Column   `json:"columns"`
+	PrimaryKey   []*KeyPart  `json:"primary_key"`
+	Indexes      []*Index    `json:"indexes,omitempty"`
+	Interleave   *Interleave `json:"interleave,omitempty"`
+	// ForeignKeys are the FOREIGN KEY constraints of the table, declared
+	// on the table or inline with REFERENCES.
+	ForeignKeys []*ForeignKey `json:"foreign_keys,omitempty"`
+	// DeletionPolicy is the row deletion policy (TTL) of the table.
+	DeletionPolicy *DeletionPolicy `json:"deletion_policy,omitempty"`
+	// Dialect is "postgresql" for tables of PostgreSQL-dialect databases,
+	// and empty for GoogleSQL.
+	Dialect string `json:"dialect,omitempty"`
+	// Dir is the directory the model is written to, if not the directory
+	// of Source, as for tables created by migrations.
+	Dir string `json:"dir,omitempty"`
+
+	// view is set on the tables standing in for views, which get
+	// read-only facades.
+	view bool
+	// changeStreams are the change streams watching the table, set by
+	// Schema.watchChanges.
+	changeStreams []tableChangeStream
+	// relations are the foreign keys loaders are generated for, and
+	// referenced is set on the tables they reference; both are set by
+	// relate.
+	relations  []relation
+	referenced bool
+}
+
+// tableChangeStream is a change stream watching a table, and the columns
+// it records, nil for all and empty for the key only.
+type tableChangeStream struct {
+	name    string
+	columns []string
+}
+
+// View is a CREATE VIEW, with its columns inferred from the tables it
+// selects from.
+type View struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema,omitempty"`
+	// SchemaQuoted is set if the schema was declared quoted.
+	SchemaQuoted bool      `json:"schema_quoted,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Pos          *Pos      `json:"pos,omitempty"`
+	Query        string    `json:"query"`
+	Columns      []*Column `json:"columns"`
+	Dialect      string    `json:"dialect,omitempty"`
+	Dir          string    `json:"dir,omitempty"`
+	Quoted       bool      `json:"quoted,omitempty"`
+}
+
+// Sequence is a CREATE SEQUENCE. Kind is its sequence_kind, such as
+// bit_reversed_positive.
+type Sequence struct {
+	Name    string            `json:"name"`
+	Schema  string            `json:"schema,omitempty"`
+	Source  string            `json:"source,omitempty"`
+	Pos     *Pos              `json:"pos,omitempty"`
+	Kind    string            `json:"kind,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// ChangeStream is a CREATE CHANGE STREAM and the tables it watches.
+type ChangeStream struct {
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"`
+	Pos    *Pos   `json:"pos,omitempty"`
+	// All is set for FOR ALL streams, which watch every table.
+	All     bool              `json:"all,omitempty"`
+	Tables  []*WatchedTable   `json:"tables,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// WatchedTable is a table of a change stream with the non-key columns it
+// records, or AllColumns.
+type WatchedTable struct {
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns,omitempty"`
+	AllColumns bool     `json:"all_columns,omitempty"`
+}
+
+type Column struct {
+	Name string `json:"name"`
+	// Quoted is set if the name was declared quoted, as for Table.
+	Quoted bool `json:"quoted,omitempty"`
+	// Source is the file declaring the column, if not the Source of its
+	// table: a migration adding or altering it.
+	Source  string            `json:"source,omitempty"`
+	Pos     *Pos              `json:"pos,omitempty"`
+	Type    string            `json:"type"`
+	Length  string            `json:"length,omitempty"`
+	NotNull bool              `json:"not_null"`
+	Options map[string]string `json:"options,omitempty"`
+	// Default is the DEFAULT expression, Generated the AS expression of a
+	// generated column, which is Stored if declared STORED.
+	Default   string `json:"default,omitempty"`
+	Generated string `json:"generated,omitempty"`
+	Stored    bool   `json:"stored,omitempty"`
+	// Sequence is the sequence the Default takes the next value of.
+	Sequence string `json:"sequence,omitempty"`
+	// Hidden columns are left out of SELECT * and of the models.
+	Hidden bool `json:"hidden,omitempty"`
+	// VectorLength is the vector_length of an embedding array.
+	VectorLength int `json:"vector_length,omitempty"`
+	// Values are the values allowed by a CHECK (column IN (...))
+	// constraint, unquoted.
+	Values []string `json:"values,omitempty"`
+	// Checks are the comparisons with literals of CHECK constraints.
+	Checks []*Check `json:"checks,omitempty"`
+	// Expr is the select list expression of a view column. Type is empty
+	// if it could not be inferred.
+	Expr string `json:"expr,omitempty"`
+
+	// references is an inline REFERENCES, which parseCreateTable moves to
+	// the foreign keys of the table.
+	references *ForeignKey
+}
+
+// ForeignKey is a FOREIGN KEY constraint on Columns, referencing
+// RefColumns of RefTable: its primary key if none are given.
+type ForeignKey struct {
+	Name       string   `json:"name,omitempty"`
+	Pos        *Pos     `json:"pos,omitempty"`
+	Columns    []string `json:"columns"`
+	RefTable   string   `json:"ref_table"`
+	RefColumns []string `json:"ref_columns,omitempty"`
+	OnDelete   string   `json:"on_delete,omitempty"`
+}
+
+// Check is a comparison such as >= 0 that a column's values must pass.
+type Check struct {
+	Op    string `json:"op"`    // =, !=, <, <=, > or >=
+	Value string `json:"value"` // an SQL literal, as written
+}
+
+type KeyPart struct {
+	Column string `json:"column"`
+	Desc   bool   `json:"desc,omitempty"`
+}
+
+type Index struct {
+	Name         string     `json:"name"`
+	Pos          *Pos       `json:"pos,omitempty"`
+	Unique       bool       `json:"unique,omitempty"`
+	NullFiltered bool       `json:"null_filtered,omitempty"`
+	Columns      []*KeyPart `json:"columns"`
+	Storing      []string   `json:"storing,omitempty"`
+	Interleave   string     `json:"interleave,omitempty"`
+	// Search indexes index TOKENLIST columns for SEARCH(), vector
+	// indexes embeddings for APPROX_ distance functions.
+	Search  bool              `json:"search,omitempty"`
+	Vector  bool              `json:"vector,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Pos is a 1-based line and column in the table's source file.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// DeletionPolicy deletes rows once their Column, a TIMESTAMP, is older
+// than Days days.
+type DeletionPolicy struct {
+	Column string `json:"column"`
+	Days   int    `json:"days"`
+}
+
+type Interleave struct {
+	Parent   string `json:"parent"`
+	OnDelete string `json:"on_delete,omitempty"`
+}
+
+// table finds a table by its name, qualified by its schema if it has one.
+func (s *Schema) table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.qualifiedName() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (t *Table) qualifiedName() string {
+	if t.Schema != "" {
+		return t.Schema + "." + t.Name
+	}
+	return t.Name
+}
+
+// dir is the directory of the model of the table.
+func (t *Table) dir() string {
+	if t.Dir != "" {
+		return t.Dir
+	}
+	return filepath.Dir(t.Source)
+}
+
+// columnSource is the file declaring column.
+func (t *Table) columnSource(column *Column) string {
+	if column.Source != "" {
+		return column.Source
+	}
+	return t.Source
+}
+
+func (t *Table) column(name string) *Column {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sqlType returns the column type the way it is looked up in
+// spannerTypeMapping, e.g. "STRING NOT NULL".
+func (c *Column) sqlType() string {
+	if c.NotNull {
+		return c.Type + " NOT NULL"
+	}
+	return c.Type
+}
+
+func writeSchemaJSON(w io.Writer, s *Schema) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+func readSchemaJSON(path string) (*Schema, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var s Schema
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decoding schema: %w", err)
+	}
+	if s.Version != SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d (expected %d)", s.Version, SchemaVersion)
+	}
+	return &s, nil
+}
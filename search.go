@@ -0,0 +1,58 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var tokenizeFullTextRegex = regexp.MustCompile(`(?is)^TOKENIZE_FULLTEXT\s*\(\s*(` + identPattern + `)`)
+
+// modelColumn reports whether column belongs in Data. Hidden columns are
+// left out of SELECT *, and TOKENLIST columns can neither be read nor
+// written; both are only there to be searched.
+func modelColumn(column *Column) bool {
+	return !column.Hidden && !strings.EqualFold(column.Type, "TOKENLIST")
+}
+
+// SearchColumn is a full-text TOKENLIST column of a search index, which
+// Search matches queries against, and the column whose text it tokenizes.
+type SearchColumn struct {
+	Name        string
+	Const       string
+	Source      string
+	SourceField string
+}
+
+// searchColumns returns the TOKENIZE_FULLTEXT columns indexed by the
+// search indexes of table, in index order.
+func searchColumns(table *Table) []SearchColumn {
+	var columns []SearchColumn
+	seen := map[string]bool{}
+	for _, index := range table.Indexes {
+		if !index.Search {
+			continue
+		}
+		for _, part := range index.Columns {
+			column := table.column(part.Column)
+			if column == nil || seen[column.Name] {
+				continue
+			}
+			m := tokenizeFullTextRegex.FindStringSubmatch(column.Generated)
+			if m == nil {
+				continue
+			}
+			source := table.column(unquoteIdent(m[1]))
+			if source == nil || !modelColumn(source) {
+				continue
+			}
+			seen[column.Name] = true
+			columns = append(columns, SearchColumn{
+				Name:        column.Name,
+				Const:       "Search" + toCamelCase(column.Name),
+				Source:      source.Name,
+				SourceField: toCamelCase(source.Name),
+			})
+		}
+	}
+	return columns
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	createSequenceRegex  = regexp.MustCompile(`(?is)^CREATE\s+SEQUENCE\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + namePattern + `)(.*)$`)
+	pgSequenceKindRegex  = regexp.MustCompile(`(?i)\bBIT_REVERSED_POSITIVE\b`)
+	nextSequenceRegex    = regexp.MustCompile(`(?is)^GET_NEXT_SEQUENCE_VALUE\s*\(\s*SEQUENCE\s+(` + namePattern + `)\s*\)$`)
+	pgNextSequenceRegex  = regexp.MustCompile(`(?is)^nextval\s*\(\s*'([^']+)'\s*\)$`)
+	sequenceOptionsRegex = regexp.MustCompile(`(?is)\bOPTIONS\s*\((.*)\)`)
+)
+
+// parseCreateSequence parses a GoogleSQL sequence with its OPTIONS, or a
+// PostgreSQL one, whose kind is a keyword.
+func parseCreateSequence(f *sourceFile, stmt span) *Sequence {
+	m := createSequenceRegex.FindStringSubmatchIndex(stmt.text)
+	sequence := &Sequence{Pos: f.pos(stmt.offset + m[2])}
+	sequence.Schema, sequence.Name = splitName(stmt.text[m[2]:m[3]])
+	rest := stmt.text[m[4]:m[5]]
+	if o := sequenceOptionsRegex.FindStringSubmatch(rest); o != nil {
+		sequence.Options = parseOptions(o[1])
+		sequence.Kind = strings.ToLower(unquoteLiteral(sequence.Options["sequence_kind"]))
+	} else if pgSequenceKindRegex.MatchString(rest) {
+		sequence.Kind = "bit_reversed_positive"
+	}
+	return sequence
+}
+
+// defaultSequence returns the sequence a DEFAULT expression takes the
+// next value of, qualified by its schema, or "".
+func defaultSequence(expr string) string {
+	expr = unwrapParens(expr)
+	if m := nextSequenceRegex.FindStringSubmatch(expr); m != nil {
+		return qualifiedName(m[1])
+	}
+	if m := pgNextSequenceRegex.FindStringSubmatch(expr); m != nil {
+		return qualifiedName(m[1])
+	}
+	return ""
+}
+
+// resolveSequences reports the columns taking their default from a
+// sequence that is not in the schema.
+func resolveSequences(schema *Schema, diags *Diagnostics) {
+	known := map[string]bool{}
+	for _, s := range schema.Sequences {
+		known[s.qualifiedName()] = true
+	}
+	for _, t := range schema.Tables {
+		for _, c := range t.Columns {
+			if c.Sequence != "" && !known[c.Sequence] {
+				diags.warnf(t.columnSource(c), c.Pos, codeUnknownRef, "column %s.%s: unknown sequence %s", t.Name, c.Name, c.Sequence)
+			}
+		}
+	}
+}
+
+func (s *Sequence) qualifiedName() string {
+	if s.Schema != "" {
+		return s.Schema + "." + s.Name
+	}
+	return s.Name
+}
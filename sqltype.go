@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlType is a column type parsed into its structure, so that element and
+// field types of ARRAY<...> and STRUCT<...> can be mapped one by one.
+type sqlType struct {
+	Base   string // upper case: INT64, ARRAY, STRUCT, ...; proto names as written
+	Args   string // parenthesized suffix such as a length
+	Elem   *sqlType
+	Fields []*structField
+	Param  string // the <...> part of any other type, e.g. PROTO<examples.Book>
+}
+
+type structField struct {
+	Name string // empty for unnamed fields
+	Type *sqlType
+}
+
+// key is the type without lengths and other arguments, as used for lookups
+// in the config's types.
+func (t *sqlType) key() string {
+	switch {
+	case t.Elem != nil:
+		return "ARRAY<" + t.Elem.key() + ">"
+	case t.Fields != nil:
+		fields := make([]string, len(t.Fields))
+		for i, f := range t.Fields {
+			fields[i] = strings.TrimSpace(f.Name + " " + f.Type.key())
+		}
+		return "STRUCT<" + strings.Join(fields, ", ") + ">"
+	case t.Param != "":
+		return t.Base + "<" + t.Param + ">"
+	}
+	return t.Base
+}
+
+// proto returns the full name of a PROTO or ENUM type, given either as
+// PROTO<name> / ENUM<name> or as the bare name from a proto bundle, and
+// PROTO or ENUM if the kind is spelled out.
+func (t *sqlType) proto() (name, kind string) {
+	switch {
+	case (t.Base == "PROTO" || t.Base == "ENUM") && t.Param != "":
+		return t.Param, t.Base
+	case strings.Contains(t.Base, "."):
+		return t.Base, ""
+	}
+	return "", ""
+}
+
+func parseSQLType(s string) (*sqlType, error) {
+	p := &typeScanner{s: s}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, fmt.Errorf("unexpected %q in type %s", p.s[p.pos:], s)
+	}
+	return t, nil
+}
+
+type typeScanner struct {
+	s   string
+	pos int
+}
+
+// peek skips white space and returns the next byte, or 0 at the end.
+func (p *typeScanner) peek() byte {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *typeScanner) ident() string {
+	p.peek()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c != '_' && c != '.' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *typeScanner) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q in type %s", c, p.s)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *typeScanner) parseType() (*sqlType, error) {
+	name := p.ident()
+	if name == "" {
+		return nil, fmt.Errorf("missing type in %s", p.s)
+	}
+	t := &sqlType{Base: name}
+	if !strings.Contains(name, ".") {
+		t.Base = strings.ToUpper(name)
+	}
+
+	if p.peek() == '<' {
+		p.pos++
+		switch t.Base {
+		case "ARRAY":
+			elem, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			t.Elem = elem
+		case "STRUCT":
+			t.Fields = []*structField{}
+			for p.peek() != '>' {
+				f, err := p.parseField()
+				if err != nil {
+					return nil, err
+				}
+				t.Fields = append(t.Fields, f)
+				if p.peek() != ',' {
+					break
+				}
+				p.pos++
+			}
+		default:
+			end := strings.IndexByte(p.s[p.pos:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced < in type %s", p.s)
+			}
+			t.Param = strings.TrimSpace(p.s[p.pos : p.pos+end])
+			p.pos += end
+		}
+		if err := p.expect('>'); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek() == '(' {
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, fmt.Errorf("unbalanced ( in type %s", p.s)
+		}
+		t.Args = strings.TrimSpace(p.s[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+	}
+	return t, nil
+}
+
+func (p *typeScanner) parseField() (*structField, error) {
+	start := p.pos
+	name := p.ident()
+	switch p.peek() {
+	case ',', '>', '(', '<':
+		// An unnamed field: the name just read is its type.
+		p.pos, name = start, ""
+	}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	return &structField{Name: name, Type: t}, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Codecs tell the generated code how values of a custom Go type reach
+// Spanner.
+const (
+	// codecSpanner: the type implements spanner.Encoder and, on its
+	// pointer, spanner.Decoder.
+	codecSpanner = "spanner"
+	// codecText: the type implements encoding.TextMarshaler and, on its
+	// pointer, encoding.TextUnmarshaler; it is stored as a STRING.
+	codecText = "text"
+	// codecJSON: the type is marshalled with encoding/json into a JSON
+	// column.
+	codecJSON = "json"
+	// codecNullArray converts ARRAY columns of Null[T] elements. It is
+	// chosen by the generator and cannot be set in the config.
+	codecNullArray = "null-array"
+	// codecEnum, codecProto and codecProtoEnum convert nullable enum,
+	// PROTO and ENUM columns, mapping nil to NULL.
+	codecEnum      = "enum"
+	codecProto     = "proto"
+	codecProtoEnum = "proto-enum"
+)
+
+// Representations of nullable columns, see Config.Nullable.
+const (
+	nullableSpanner = "spanner"
+	nullablePointer = "pointer"
+	nullableGeneric = "generic"
+)
+
+// TypeMapping maps a column to a Go type. In the config it is either just
+// the Go type, or an object also naming the package to import and the
+// codec used to convert values.
+type TypeMapping struct {
+	Type   string `json:"type"`
+	Import string `json:"import,omitempty"` // import path, optionally preceded by a name
+	Codec  string `json:"codec,omitempty"`
+}
+
+func (m *TypeMapping) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Type); err == nil {
+		return nil
+	}
+	type mapping TypeMapping
+	return json.Unmarshal(data, (*mapping)(m))
+}
+
+func (m TypeMapping) validate() error {
+	if m.Type == "" {
+		return fmt.Errorf("missing Go type")
+	}
+	switch m.Codec {
+	case "", codecSpanner, codecText, codecJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown codec %q (want %s, %s or %s)", m.Codec, codecSpanner, codecText, codecJSON)
+}
+
+// importSpec renders Import as it appears in an import block.
+func (m TypeMapping) importSpec() string {
+	if m.Import == "" {
+		return ""
+	}
+	if name, path, ok := strings.Cut(m.Import, " "); ok {
+		return fmt.Sprintf("%s %q", name, strings.TrimSpace(path))
+	}
+	return fmt.Sprintf("%q", m.Import)
+}
+
+// typeRegistry maps columns to Go types: the config's columns and types
+// first, then the built-in spannerTypeMapping and spannerArrTypeMapping.
+type typeRegistry struct {
+	columns  map[string]TypeMapping
+	types    map[string]TypeMapping
+	protos   map[string]ProtoPackage
+	strict   bool
+	nullable string
+}
+
+func newTypeRegistry(cfg *Config) *typeRegistry {
+	r := &typeRegistry{
+		columns:  cfg.Columns,
+		types:    map[string]TypeMapping{},
+		protos:   cfg.Protos,
+		strict:   cfg.strict(),
+		nullable: cfg.Nullable,
+	}
+	if r.nullable == "" {
+		r.nullable = nullableSpanner
+	}
+	for sqlType, m := range cfg.Types {
+		r.types[normalizeSQLType(sqlType)] = m
+	}
+	return r
+}
+
+// typeDecls collects what the Go types of a table need besides the
+// types themselves: generated struct types and imports.
+type typeDecls struct {
+	structs []StructType
+	enums   []EnumType
+	imports map[string]bool
+}
+
+// goType returns the Go type of column. Unmapped types fall back to
+// interface{} together with a diagnostic, which is an error in strict mode
+// and a warning otherwise.
+func (r *typeRegistry) goType(table *Table, column *Column, decls *typeDecls) (TypeMapping, *Diagnostic) {
+	if m, ok := r.columns[table.qualifiedName()+"."+column.Name]; ok {
+		return implicitImport(m), nil
+	}
+	if m, ok := r.columns["*."+column.Name]; ok {
+		return implicitImport(m), nil
+	}
+
+	if len(column.Values) > 0 {
+		if m, ok := r.enumType(column, decls); ok {
+			return m, nil
+		}
+	}
+
+	for _, key := range typeKeys(column) {
+		if m, ok := r.types[key]; ok {
+			return implicitImport(m), nil
+		}
+	}
+
+	if column.VectorLength > 0 {
+		return TypeMapping{Type: vectorGoType(column)}, nil
+	}
+
+	if t, err := parseSQLType(column.Type); err == nil {
+		if m, ok := r.mapType(t, !column.NotNull, toCamelCase(column.Name), decls); ok {
+			return m, nil
+		}
+	}
+
+	what := column.sqlType()
+	if column.Type == "" {
+		what = "view expression " + column.Expr
+	}
+	diag := &Diagnostic{
+		File:     table.columnSource(column),
+		Severity: SeverityWarning,
+		Code:     codeUnknownType,
+		Message:  fmt.Sprintf("column %s: no Go type for %s, using interface{}", column.Name, what),
+	}
+	if r.strict {
+		diag.Severity = SeverityError
+		diag.Message = fmt.Sprintf("column %s: no Go type for %s; map it in \"types\" of %s", column.Name, what, defaultConfigFile)
+	}
+	if column.Pos != nil {
+		diag.Line, diag.Column = column.Pos.Line, column.Pos.Column
+	}
+	return TypeMapping{Type: "interface{}"}, diag
+}
+
+// mapType maps t, looking up the config's types before the built-in
+// mapping. STRUCT types become Go structs named name plus Value, added to
+// decls.
+func (r *typeRegistry) mapType(t *sqlType, nullable bool, name string, decls *typeDecls) (TypeMapping, bool) {
+	key := normalizeSQLType(t.key())
+	if !nullable {
+		if m, ok := r.types[key+" NOT NULL"]; ok {
+			return implicitImport(m), true
+		}
+	}
+	if m, ok := r.types[key]; ok {
+		return implicitImport(m), true
+	}
+
+	if protoName, kind := t.proto(); protoName != "" {
+		return r.protoType(protoName, kind, nullable)
+	}
+
+	switch {
+	case t.Elem != nil:
+		// Array elements can always be NULL.
+		elem, ok := r.mapType(t.Elem, true, name+"Item", decls)
+		if !ok {
+			return TypeMapping{}, false
+		}
+		m := TypeMapping{Type: "[]" + elem.Type, Import: elem.Import}
+		if strings.HasPrefix(elem.Type, "Null[") {
+			m.Codec = codecNullArray
+		}
+		return m, true
+
+	case t.Fields != nil:
+		// Suffixed like enums, to keep clear of the Field constants.
+		st := StructType{Name: name + "Value"}
+		for i, f := range t.Fields {
+			field := StructField{Name: toCamelCase(f.Name), Tag: f.Name}
+			if f.Name == "" {
+				field.Name = fmt.Sprintf("F%d", i)
+			}
+			fm, ok := r.mapType(f.Type, true, name+field.Name, decls)
+			if !ok {
+				return TypeMapping{}, false
+			}
+			if spec := fm.importSpec(); spec != "" {
+				decls.imports[spec] = true
+			}
+			field.Type = fm.Type
+			st.Fields = append(st.Fields, field)
+		}
+		decls.structs = append(decls.structs, st)
+		if nullable {
+			return TypeMapping{Type: "*" + st.Name}, true
+		}
+		return TypeMapping{Type: st.Name}, true
+	}
+
+	if !nullable {
+		if goType, ok := spannerTypeMapping[t.Base+" NOT NULL"]; ok {
+			return builtinMapping(goType), true
+		}
+	} else if goType, ok := spannerTypeMapping[t.Base+" NOT NULL"]; ok && r.nullable != nullableSpanner {
+		return builtinMapping(r.nullableType(goType)), true
+	}
+	goType, ok := spannerTypeMapping[t.Base]
+	return builtinMapping(goType), ok
+}
+
+// builtinImports are the import paths of the packages used by
+// spannerTypeMapping, besides spanner itself.
+var builtinImports = map[string]string{
+	"time":  "time",
+	"big":   "math/big",
+	"civil": "cloud.google.com/go/civil",
+}
+
+func builtinMapping(goType string) TypeMapping {
+	name := strings.TrimPrefix(strings.TrimLeft(goType, "[]*"), "Null[")
+	pkg, _, _ := strings.Cut(name, ".")
+	return TypeMapping{Type: goType, Import: builtinImports[pkg]}
+}
+
+// implicitImport fills in the import of config mappings to the packages of
+// the built-in types, which need not be named.
+func implicitImport(m TypeMapping) TypeMapping {
+	if m.Import == "" {
+		m.Import = builtinMapping(m.Type).Import
+	}
+	return m
+}
+
+// nullableType returns how a nullable value of the built-in Go type t is
+// represented. Types that can hold NULL themselves are kept.
+func (r *typeRegistry) nullableType(t string) string {
+	if strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "spanner.") || t == "interface{}" {
+		return t
+	}
+	switch r.nullable {
+	case nullablePointer:
+		return "*" + t
+	case nullableGeneric:
+		return "Null[" + t + "]"
+	}
+	return t
+}
+
+// typeKeys are the keys column is looked up by in the config's types, in
+// order: a key with the length, such as STRING(36), wins over the bare
+// type, and an exact match, nullability included, over one without it.
+func typeKeys(column *Column) []string {
+	var keys []string
+	if column.Length != "" {
+		sized := column.Type + "(" + column.Length + ")"
+		if column.NotNull {
+			keys = append(keys, normalizeSQLType(sized+" NOT NULL"))
+		}
+		keys = append(keys, normalizeSQLType(sized))
+	}
+	return append(keys, normalizeSQLType(column.sqlType()), normalizeSQLType(column.Type))
+}
+
+// normalizeSQLType upper-cases s and collapses its spaces, dropping those
+// around parentheses, so that "string (36)" is looked up as STRING(36).
+func normalizeSQLType(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, space := range []string{" (", "( ", " )"} {
+		s = strings.ReplaceAll(s, space, strings.TrimSpace(space))
+	}
+	return strings.ToUpper(s)
+}
+
+// strict reports whether unmapped column types are errors. Unless set in
+// the config or on the command line, it is on in CI.
+func (c *Config) strict() bool {
+	if c.Strict != nil {
+		return *c.Strict
+	}
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestGoTypeConfigKeys(t *testing.T) {
+	r := newTypeRegistry(&Config{Types: map[string]TypeMapping{
+		"STRING(36) NOT NULL": {Type: "uuid.UUID", Import: "github.com/google/uuid"},
+		"string (36)":         {Type: "uuid.NullUUID", Import: "github.com/google/uuid"},
+		"STRING NOT NULL":     {Type: "Text"},
+		"INT64":               {Type: "Count"},
+	}})
+	tests := []struct {
+		column Column
+		want   string
+	}{
+		{Column{Name: "id", Type: "STRING", Length: "36", NotNull: true}, "uuid.UUID"},
+		{Column{Name: "ref", Type: "STRING", Length: "36"}, "uuid.NullUUID"},
+		{Column{Name: "name", Type: "STRING", Length: "MAX", NotNull: true}, "Text"},
+		{Column{Name: "code", Type: "STRING", Length: "40", NotNull: true}, "Text"},
+		{Column{Name: "total", Type: "INT64", NotNull: true}, "Count"},
+		{Column{Name: "bio", Type: "STRING", Length: "MAX"}, "spanner.NullString"},
+	}
+	for _, tt := range tests {
+		decls := &typeDecls{imports: map[string]bool{}}
+		m, diag := r.goType(&Table{Name: "users"}, &tt.column, decls)
+		if diag != nil {
+			t.Errorf("%s: %v", tt.column.Name, diag)
+		}
+		if m.Type != tt.want {
+			t.Errorf("%s %s(%s): got %s, want %s", tt.column.Name, tt.column.Type, tt.column.Length, m.Type, tt.want)
+		}
+	}
+}
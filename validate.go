@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FieldCheck is a Check with its value as a Go literal, for the generated
+// Validate.
+type FieldCheck struct {
+	Op    string
+	Value string
+}
+
+// setConstraints fills in what the generated Validate checks for the
+// column: NOT NULL for Go types that can be nil and without a default,
+// STRING and BYTES lengths, vector lengths, enum values and CHECK
+// comparisons. Generated columns cannot be written at all.
+func (f *Field) setConstraints(column *Column, enum bool) {
+	f.Required = column.NotNull && nilable(f.Type) && column.Default == ""
+	switch column.Type {
+	case "STRING", "BYTES":
+		f.MaxLength, _ = strconv.Atoi(column.Length)
+	}
+	f.VectorLength = column.VectorLength
+	f.Enum = enum
+	for _, c := range column.Checks {
+		if value, ok := goLiteral(c.Value); ok {
+			f.Checks = append(f.Checks, FieldCheck{Op: c.Op, Value: value})
+		}
+	}
+	f.Constrained = f.Required || f.MaxLength > 0 || f.VectorLength > 0 || f.Enum || len(f.Checks) > 0 || f.Generated
+}
+
+// nilable reports whether values of the Go type t can be nil, which the
+// Spanner client writes as NULL.
+func nilable(t string) bool {
+	return strings.HasPrefix(t, "*") || strings.HasPrefix(t, "[]") ||
+		strings.HasPrefix(t, "map[") || t == "interface{}" || t == "any"
+}
+
+// goLiteral turns an SQL literal into a Go constant of the type the
+// generated code compares it as: string, int64 or float64.
+func goLiteral(sql string) (string, bool) {
+	switch {
+	case intLiteralRegex.MatchString(sql):
+		return "int64(" + sql + ")", true
+	case numLiteralRegex.MatchString(sql):
+		return "float64(" + sql + ")", true
+	case len(sql) >= 2 && (sql[0] == '\'' || sql[0] == '"') && sql[len(sql)-1] == sql[0]:
+		return strconv.Quote(unescapeLiteral(sql[1 : len(sql)-1])), true
+	}
+	return "", false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var vectorTypeRegex = regexp.MustCompile(`(?i)^ARRAY<\s*(FLOAT32|FLOAT64)\s*>\s*\(\s*vector_length\s*=>\s*(\d+)\s*\)$`)
+
+// parseVectorType splits ARRAY<FLOAT32>(vector_length=>N) into the array
+// type and N, or returns 0 for any other type.
+func parseVectorType(sqlType string) (string, int) {
+	m := vectorTypeRegex.FindStringSubmatch(sqlType)
+	if m == nil {
+		return sqlType, 0
+	}
+	n, _ := strconv.Atoi(m[2])
+	return "ARRAY<" + strings.ToUpper(m[1]) + ">", n
+}
+
+// vectorGoType is the Go type of a vector column: a plain slice, as the
+// elements of embeddings are never NULL.
+func vectorGoType(column *Column) string {
+	if strings.Contains(column.Type, "FLOAT64") {
+		return "[]float64"
+	}
+	return "[]float32"
+}
+
+// VectorColumn is a column with a vector_length, which NearestNeighbors
+// searches, and the vector index on it, if any.
+type VectorColumn struct {
+	Name   string
+	Field  string
+	Type   string
+	Length int
+	// Method is NearestNeighbors, suffixed with Field if the table has
+	// several vector columns.
+	Method string
+	// Index is the vector index used for approximate searches with its
+	// Distance, and LeavesToSearch is its num_leaves_to_search.
+	Index          string
+	Distance       string
+	LeavesToSearch int
+}
+
+// vectorColumns returns the vector columns of table.
+func vectorColumns(table *Table) []VectorColumn {
+	var columns []VectorColumn
+	for _, c := range table.Columns {
+		if c.VectorLength > 0 && modelColumn(c) {
+			columns = append(columns, VectorColumn{
+				Name:   c.Name,
+				Field:  toCamelCase(c.Name),
+				Type:   vectorGoType(c),
+				Length: c.VectorLength,
+				Method: "NearestNeighbors",
+			})
+		}
+	}
+	for i := range columns {
+		if len(columns) > 1 {
+			columns[i].Method += columns[i].Field
+		}
+		for _, index := range table.Indexes {
+			if !index.Vector || len(index.Columns) == 0 || index.Columns[0].Column != columns[i].Name {
+				continue
+			}
+			columns[i].Index = index.Name
+			columns[i].Distance = strings.ToUpper(unquoteLiteral(index.Options["distance_type"]))
+			if columns[i].Distance == "" {
+				columns[i].Distance = "COSINE"
+			}
+			// Search about 1% of the leaves, at least one.
+			leaves, _ := strconv.Atoi(index.Options["num_leaves"])
+			columns[i].LeavesToSearch = max(leaves/100, 1)
+			break
+		}
+	}
+	return columns
+}
+
+// unquoteLiteral strips the quotes of a string literal.
+func unquoteLiteral(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return unescapeLiteral(s[1 : len(s)-1])
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	createViewRegex = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?VIEW\s+(` + namePattern + `)\s+(?:SQL\s+SECURITY\s+(?:INVOKER|DEFINER)\s+)?AS\s+(.*)$`)
+	selectRegex     = regexp.MustCompile(`(?is)^SELECT\s+(?:(?:ALL|DISTINCT)\s+)?`)
+	fromRegex       = regexp.MustCompile(`(?i)\bFROM\b`)
+	fromEndRegex    = regexp.MustCompile(`(?i)\b(?:WHERE|GROUP|HAVING|ORDER|LIMIT|UNION|INTERSECT|EXCEPT|WINDOW|QUALIFY)\b`)
+	tableRefRegex   = regexp.MustCompile(`(?is)(?:^|\bJOIN|,)\s*(` + namePattern + `)(?:\s+(?:AS\s+)?(` + identPattern + `))?`)
+	outerJoinRegex  = regexp.MustCompile(`(?i)\b(?:LEFT|RIGHT|FULL)\b`)
+	aliasRegex      = regexp.MustCompile(`(?is)^(.*?)\s+(?:AS\s+)?(` + identPattern + `)$`)
+	columnRefRegex  = regexp.MustCompile(`^(?:(` + identPattern + `)\s*\.\s*)?(` + identPattern + `|\*)$`)
+	castRegex       = regexp.MustCompile(`(?is)^(?:SAFE_)?CAST\s*\((.*)\s+AS\s+(.+)\)$`)
+	countRegex      = regexp.MustCompile(`(?is)^COUNT\s*\(`)
+)
+
+// joinKeywords can follow a table in FROM and are not its alias.
+var joinKeywords = map[string]bool{
+	"ON": true, "USING": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true,
+	"FULL": true, "CROSS": true, "OUTER": true, "WHERE": true, "TABLESAMPLE": true,
+}
+
+// parseCreateView parses a view up to its query; the columns are resolved
+// by resolveView once every table is known.
+func parseCreateView(f *sourceFile, stmt span) *View {
+	m := createViewRegex.FindStringSubmatchIndex(stmt.text)
+	view := &View{
+		Pos:   f.pos(stmt.offset + m[2]),
+		Query: strings.TrimSpace(stmt.text[m[4]:m[5]]),
+	}
+	view.Schema, view.Name = splitName(stmt.text[m[2]:m[3]])
+	view.Quoted = nameQuoted(stmt.text[m[2]:m[3]])
+	view.SchemaQuoted = schemaQuoted(stmt.text[m[2]:m[3]])
+	return view
+}
+
+// resolveView infers the columns of view from its select list: columns of
+// the tables in FROM keep their types, CAST gives the type it casts to and
+// COUNT is an INT64. Other expressions are left without a type. Outer
+// joins make every column of the tables nullable.
+func resolveView(schema *Schema, view *View, diags *Diagnostics) {
+	query := unwrapParens(view.Query)
+	sel := selectRegex.FindStringIndex(query)
+	if sel == nil {
+		diags.report(view.Source, view.Pos, codeSyntax, fmt.Errorf("view %s: cannot parse query, expected SELECT", view.Name))
+		return
+	}
+	query = query[sel[1]:]
+	list, from := query, ""
+	if loc := findTopLevel(query, fromRegex); loc != nil {
+		list, from = query[:loc[0]], query[loc[1]:]
+		if end := findTopLevel(from, fromEndRegex); end != nil {
+			from = from[:end[0]]
+		}
+	}
+
+	// Tables by alias, and by name when not aliased.
+	var tables []*Table
+	aliases := map[string]*Table{}
+	top := topLevel(from)
+	for _, loc := range tableRefRegex.FindAllStringSubmatchIndex(from, -1) {
+		if loc[0] < len(from) && !top[loc[0]] {
+			continue // a comma inside ON or USING
+		}
+		ref := make([]string, 3)
+		for i := range ref {
+			if loc[2*i] >= 0 {
+				ref[i] = from[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		name := qualifiedName(ref[1])
+		table := schema.table(name)
+		if table == nil {
+			diags.warnf(view.Source, view.Pos, codeUnknownRef, "view %s: unknown table %s", view.Name, name)
+			continue
+		}
+		if view.Dialect == "" {
+			view.Dialect = table.Dialect
+		}
+		tables = append(tables, table)
+		aliases[strings.ToLower(table.Name)] = table
+		if alias := ref[2]; alias != "" && !joinKeywords[strings.ToUpper(alias)] {
+			aliases[strings.ToLower(unquoteIdent(alias))] = table
+		}
+	}
+	nullable := outerJoinRegex.MatchString(from)
+
+	for _, item := range splitTopLevel(list, ',') {
+		expr, name, quoted := item, "", false
+		if m := aliasRegex.FindStringSubmatch(item); m != nil && !strings.HasSuffix(m[1], ".") {
+			expr, name, quoted = strings.TrimSpace(m[1]), unquoteIdent(m[2]), nameQuoted(m[2])
+		}
+
+		var columns []*Column
+		fromTable := false
+		switch ref := columnRefRegex.FindStringSubmatch(expr); {
+		case ref != nil:
+			columns = viewColumns(tables, aliases, unquoteIdent(ref[1]), unquoteIdent(ref[2]))
+			if columns == nil {
+				diags.warnf(view.Source, view.Pos, codeUnknownRef, "view %s: unknown column %s", view.Name, expr)
+				columns = []*Column{{Name: unquoteIdent(ref[2]), Quoted: nameQuoted(ref[2])}}
+			}
+			fromTable = true
+		case castRegex.MatchString(expr):
+			sqlType, _ := readType(castRegex.FindStringSubmatch(expr)[2])
+			columns = []*Column{{Type: strings.ToUpper(sqlType)}}
+			if m := typeLengthRegex.FindStringSubmatch(sqlType); m != nil {
+				columns[0].Type, columns[0].Length = strings.ToUpper(m[1]), strings.ToUpper(m[2])
+			}
+		case countRegex.MatchString(expr):
+			columns = []*Column{{Type: "INT64", NotNull: true}}
+		default:
+			columns = []*Column{{}}
+		}
+
+		for _, c := range columns {
+			column := *c
+			column.Source, column.Pos = "", view.Pos
+			column.Expr = expr
+			column.Default, column.Generated, column.Sequence, column.Stored, column.Checks = "", "", "", false, nil
+			if name != "" {
+				column.Name, column.Quoted = name, quoted
+			}
+			if column.Name == "" {
+				diags.warnf(view.Source, view.Pos, codeSyntax, "view %s: expression %s has no name", view.Name, expr)
+				continue
+			}
+			if fromTable && nullable {
+				column.NotNull = false
+			}
+			view.Columns = append(view.Columns, &column)
+		}
+	}
+}
+
+// viewColumns finds the columns a select list entry refers to: the column
+// name, or all of them for *, of the table called qualifier, or of any of
+// the tables if there is no qualifier.
+func viewColumns(tables []*Table, aliases map[string]*Table, qualifier, name string) []*Column {
+	candidates := tables
+	if qualifier != "" {
+		table, ok := aliases[strings.ToLower(qualifier)]
+		if !ok {
+			return nil
+		}
+		candidates = []*Table{table}
+	}
+	var columns []*Column
+	for _, t := range candidates {
+		if name == "*" {
+			for _, c := range t.Columns {
+				if !c.Hidden {
+					columns = append(columns, c)
+				}
+			}
+		} else if c := t.column(name); c != nil {
+			return []*Column{c}
+		}
+	}
+	return columns
+}
+
+// findTopLevel returns the location of the first match of re in s outside
+// of parentheses and strings.
+func findTopLevel(s string, re *regexp.Regexp) []int {
+	top := topLevel(s)
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if top[loc[0]] {
+			return loc
+		}
+	}
+	return nil
+}
+
+// table returns the view as a table to render its read-only facade from.
+func (v *View) table() *Table {
+	return &Table{
+		Name:         v.Name,
+		Schema:       v.Schema,
+		SchemaQuoted: v.SchemaQuoted,
+		Source:       v.Source,
+		Pos:          v.Pos,
+		Columns:      v.Columns,
+		Dialect:      v.Dialect,
+		Dir:          v.Dir,
+		Quoted:       v.Quoted,
+		view:         true,
+	}
+}
+
+// models returns the tables and views to generate facades for. A table
+// of a named schema or a view in the directory of another model gets a
+// package of its own below it, named like the packages of migrated ones.
+func (s *Schema) models() []*Table {
+	s.watchChanges()
+	tables := append([]*Table(nil), s.Tables...)
+	dirs := map[string]bool{}
+	for _, t := range tables {
+		if t.Schema == "" {
+			dirs[filepath.Clean(t.dir())] = true
+		}
+	}
+	for i, t := range tables {
+		if t.Schema == "" {
+			continue
+		}
+		if dirs[filepath.Clean(t.dir())] {
+			moved := *t
+			moved.Dir = modelDir(t.dir(), t.Schema, t.Name)
+			tables[i] = &moved
+		}
+		dirs[filepath.Clean(tables[i].dir())] = true
+	}
+	for _, v := range s.Views {
+		t := v.table()
+		if dirs[filepath.Clean(t.dir())] {
+			t.Dir = modelDir(t.dir(), t.Schema, v.Name)
+		}
+		dirs[filepath.Clean(t.dir())] = true
+		tables = append(tables, t)
+	}
+	return tables
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runWatch polls the schema files, the config and the templates and
+// regenerates whenever one of them changes. Errors are printed and the
+// watch goes on; the cache keeps untouched packages from being rewritten.
+func runWatch(args []string) {
+	flags := flag.NewFlagSet("model-gen watch", flag.ExitOnError)
+	interval := flags.Duration("interval", 500*time.Millisecond, "how often files are polled for changes")
+	workers := flags.Int("j", runtime.GOMAXPROCS(0), "number of files parsed and rendered concurrently")
+	diagFormat := diagnosticsFlag(flags)
+	cfgFlags := newConfigFlags(flags)
+	flags.Parse(args)
+
+	// Explicit files (or stdin) are read once; otherwise discovery runs on
+	// every poll so new schema files and migrations are picked up.
+	var explicit []string
+	if flags.NArg() > 0 {
+		explicit = schemaFiles(nil, flags.Args(), &Diagnostics{})
+	}
+
+	out := io.Writer(os.Stderr)
+	if *diagFormat == "json" {
+		out = os.Stdout
+	}
+
+	var last snapshot
+	for ; ; time.Sleep(*interval) {
+		watched := []string{cfgFlags.path}
+		diags := &Diagnostics{}
+
+		cfg, err := cfgFlags.load()
+		paths := explicit
+		if err == nil {
+			if paths == nil {
+				paths = schemaFiles(cfg, nil, diags)
+			}
+			watched = append(watched, templateFiles(cfg)...)
+			watched = append(watched, paths...)
+		}
+
+		snap := takeSnapshot(watched)
+		changed := snap.changed(last)
+		if last != nil && len(changed) == 0 {
+			continue
+		}
+		if last != nil {
+			if wd, err := os.Getwd(); err == nil {
+				for i := range changed {
+					changed[i] = relSlash(wd, changed[i])
+				}
+			}
+			log.Printf("Changed: %s\n", strings.Join(changed, ", "))
+		}
+		last = snap
+
+		if err != nil {
+			diags.report(cfgFlags.path, nil, codeConfig, err)
+		} else {
+			generateFiles(cfg, paths, *workers, false, true, diags)
+		}
+		diags.print(out, *diagFormat)
+	}
+}
+
+// snapshot maps watched paths to their modification time and size. Missing
+// files are recorded as such so their creation is noticed.
+type snapshot map[string]fileStamp
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func takeSnapshot(paths []string) snapshot {
+	snap := snapshot{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			snap[path] = fileStamp{}
+			continue
+		}
+		snap[path] = fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+	}
+	return snap
+}
+
+// changed returns the sorted paths that differ between old and s.
+func (s snapshot) changed(old snapshot) []string {
+	var paths []string
+	for path, stamp := range s {
+		if prev, ok := old[path]; !ok || prev != stamp {
+			paths = append(paths, path)
+		}
+	}
+	for path := range old {
+		if _, ok := s[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func templateFiles(cfg *Config) []string {
+	if cfg.Templates == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(cfg.Templates)
+	if err != nil {
+		return []string{filepath.Join(cfg.Templates, structTemplateFile)}
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			paths = append(paths, filepath.Join(cfg.Templates, e.Name()))
+		}
+	}
+	return paths
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotChanged(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"models/users/schema.sql":  "CREATE TABLE users (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+		"models/orders/schema.sql": "CREATE TABLE orders (id STRING(36) NOT NULL) PRIMARY KEY (id);",
+	})
+	users := filepath.Join(dir, "models", "users", "schema.sql")
+	orders := filepath.Join(dir, "models", "orders", "schema.sql")
+	config := filepath.Join(dir, defaultConfigFile)
+	paths := []string{config, users, orders}
+
+	first := takeSnapshot(paths)
+	if first[config].exists || !first[users].exists {
+		t.Fatalf("snapshot = %+v", first)
+	}
+	if got := takeSnapshot(paths).changed(first); len(got) != 0 {
+		t.Errorf("unchanged files reported: %q", got)
+	}
+	if got := first.changed(nil); !reflect.DeepEqual(got, []string{config, orders, users}) {
+		t.Errorf("first poll = %q", got)
+	}
+
+	// An edit that keeps the size is told by the modification time.
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(users, []byte("CREATE TABLE users (id STRING(99) NOT NULL) PRIMARY KEY (id);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(users, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := takeSnapshot(paths)
+	if got := second.changed(first); !reflect.DeepEqual(got, []string{config, users}) {
+		t.Errorf("edited and created = %q", got)
+	}
+
+	// A file no longer watched counts as a change, as does a removed one.
+	if err := os.Remove(users); err != nil {
+		t.Fatal(err)
+	}
+	third := takeSnapshot([]string{config, users})
+	if got := third.changed(second); !reflect.DeepEqual(got, []string{orders, users}) {
+		t.Errorf("removed and unwatched = %q", got)
+	}
+}
+
+func TestTemplateFiles(t *testing.T) {
+	if got := templateFiles(&Config{}); got != nil {
+		t.Errorf("builtin templates = %q", got)
+	}
+	dir := writeFiles(t, map[string]string{
+		"tmpl/" + structTemplateFile: "",
+		"tmpl/header.tmpl":           "",
+		"tmpl/partials/field.tmpl":   "",
+	})
+	tmpl := filepath.Join(dir, "tmpl")
+	want := []string{filepath.Join(tmpl, "header.tmpl"), filepath.Join(tmpl, structTemplateFile)}
+	if got := templateFiles(&Config{Templates: tmpl}); !reflect.DeepEqual(got, want) {
+		t.Errorf("templates = %q, want %q", got, want)
+	}
+	// A missing directory still watches the template it would hold.
+	missing := filepath.Join(dir, "missing")
+	if got := templateFiles(&Config{Templates: missing}); !reflect.DeepEqual(got, []string{filepath.Join(missing, structTemplateFile)}) {
+		t.Errorf("missing templates = %q", got)
+	}
+}